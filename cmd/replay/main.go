@@ -0,0 +1,272 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command replay plays the frames a capture wrote to the controller against
+// the simulator (-sim) or a serial port (-port), recording the new traffic to
+// -out. With -diff it compares the new traffic, or the capture itself when
+// nothing is replayed, with a previous capture and exits with status 1 when
+// they differ:
+//
+//	replay -sim -out new.cap -diff old.cap -ignore positions run.cap
+//	replay -diff before.cap -direction tx -tolerance 1 after.cap
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jacobsa/go-serial/serial"
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver"
+	"github.com/smilelinkd/digitalbow-mapper/driver/capture"
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+	"github.com/smilelinkd/digitalbow-mapper/simulator"
+)
+
+// readTimeout lets the reader of a serial port notice the end of a replay.
+const readTimeout = 100 * time.Millisecond
+
+func main() {
+	var (
+		port, parity, out, previous, direction, ignore string
+		baudRate, tolerance, maxDifferences            int
+		sim                                            bool
+		speed                                          float64
+		settle                                         time.Duration
+	)
+	flag.StringVar(&port, "port", "", "serial port to replay the capture to")
+	flag.IntVar(&baudRate, "baud", 115200, "baud rate of -port")
+	flag.StringVar(&parity, "parity", "none", "parity of -port: none, even or odd")
+	flag.BoolVar(&sim, "sim", false, "replay the capture to the built-in simulator")
+	flag.StringVar(&out, "out", "", "record the replayed traffic to this capture")
+	flag.Float64Var(&speed, "speed", 1, "replay speed, 0 to send the frames without delay")
+	flag.DurationVar(&settle, "settle", time.Second, "time to read the answers after the last frame")
+	flag.StringVar(&previous, "diff", "", "previous capture to compare with")
+	flag.StringVar(&direction, "direction", "both", "frames to compare: tx, rx or both")
+	flag.IntVar(&tolerance, "tolerance", 0, "largest cylinder position difference in counts still equal")
+	flag.StringVar(&ignore, "ignore", "", "comma separated commands not compared, e.g. positions,status")
+	flag.IntVar(&maxDifferences, "max", 20, "differences printed per direction")
+	klog.InitFlags(nil)
+	flag.Parse()
+	defer klog.Flush()
+	if flag.NArg() != 1 || (port != "" && sim) {
+		fmt.Fprintln(os.Stderr, "usage: replay [-sim | -port path] [-out capture] [-diff capture] capture")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	records, err := capture.ReadFile(flag.Arg(0))
+	if err != nil {
+		klog.Fatal(err)
+	}
+	if sim || port != "" {
+		var rw io.ReadWriteCloser
+		if sim {
+			rw = startSimulator()
+		} else if rw, err = openPort(port, baudRate, parity); err != nil {
+			klog.Fatal(err)
+		}
+		if records, err = replay(records, rw, out, speed, settle); err != nil {
+			klog.Fatal(err)
+		}
+	}
+	if previous == "" {
+		return
+	}
+
+	options := capture.DiffOptions{Tolerance: tolerance}
+	if options.Ignore, err = parseCommands(ignore); err != nil {
+		klog.Fatal(err)
+	}
+	directions := []capture.Direction{capture.Tx, capture.Rx}
+	switch direction {
+	case "both":
+	case string(capture.Tx), string(capture.Rx):
+		directions = []capture.Direction{capture.Direction(direction)}
+	default:
+		klog.Fatalf("invalid direction %q", direction)
+	}
+	previousRecords, err := capture.ReadFile(previous)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	differ := false
+	for _, options.Direction = range directions {
+		differences := capture.Diff(previousRecords, records, options)
+		fmt.Printf("%s: %d differences\n", options.Direction, len(differences))
+		for i, difference := range differences {
+			if i == maxDifferences {
+				fmt.Println("  ...")
+				break
+			}
+			fmt.Printf("  %v\n", difference)
+		}
+		differ = differ || len(differences) > 0
+	}
+	if differ {
+		os.Exit(1)
+	}
+}
+
+// startSimulator serves a simulator on one end of a pipe and returns the
+// other end.
+func startSimulator() io.ReadWriteCloser {
+	mapper, controller := net.Pipe()
+	go func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		simulator.New(simulator.DefaultConfig()).Serve(controller, stop)
+	}()
+	return mapper
+}
+
+func openPort(name string, baudRate int, parity string) (io.ReadWriteCloser, error) {
+	options, err := driver.SerialOptions(driver.BowRTUConfig{
+		SerialName: name,
+		BaudRate:   baudRate,
+		Parity:     parity,
+		Timeout:    readTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return serial.Open(options)
+}
+
+// replay writes the tx frames of records to rw at their recorded offsets,
+// scaled by speed, and returns the frames written and read. They are also
+// recorded to the capture out when not empty.
+func replay(records []capture.Record, rw io.ReadWriteCloser, out string, speed float64, settle time.Duration) ([]capture.Record, error) {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	if out != "" {
+		file, err := os.Create(out)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		w = io.MultiWriter(&buf, file)
+	}
+	writer, err := capture.NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		decoder := protocol.NewDecoder(rw)
+		for {
+			frame, err := decoder.ReadFrame()
+			select {
+			case <-done:
+				return
+			default:
+			}
+			switch {
+			case err == nil:
+				data, _ := frame.Encode()
+				if err = writer.Record(capture.Rx, data); err != nil {
+					klog.Error(err)
+				}
+			case errors.Is(err, protocol.ErrChecksum):
+				klog.Warning(err)
+			case err == io.EOF || err == io.ErrUnexpectedEOF:
+				// A read timeout of the serial port.
+			default:
+				klog.Error(err)
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	sent := 0
+	for _, record := range records {
+		if record.Direction != capture.Tx {
+			continue
+		}
+		if speed > 0 {
+			time.Sleep(time.Until(start.Add(time.Duration(float64(record.Offset) / speed))))
+		}
+		if _, err = rw.Write(record.Data); err != nil {
+			return nil, err
+		}
+		if err = writer.Record(capture.Tx, record.Data); err != nil {
+			return nil, err
+		}
+		sent++
+	}
+	time.Sleep(settle)
+	close(done)
+	rw.Close()
+	<-read
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	klog.V(1).Infof("Replayed %d frames in %v", sent, time.Since(start))
+
+	reader, err := capture.NewReader(&buf)
+	if err != nil {
+		return nil, err
+	}
+	var replayed []capture.Record
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return replayed, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		replayed = append(replayed, record)
+	}
+}
+
+// parseCommands parses a comma separated list of command names.
+func parseCommands(list string) ([]protocol.Command, error) {
+	var commands []protocol.Command
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		command, ok := commandByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown command %q", name)
+		}
+		commands = append(commands, command)
+	}
+	return commands, nil
+}
+
+func commandByName(name string) (protocol.Command, bool) {
+	for command := protocol.CommandHandshake; command <= protocol.CommandAck; command++ {
+		if command.String() == name {
+			return command, true
+		}
+	}
+	return 0, false
+}
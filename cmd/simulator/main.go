@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command simulator runs a virtual bow controller on a pseudo-terminal. Point
+// the serialPort of the device instance at the printed path, or at -link,
+// leave serialType unset, as a pseudo-terminal has no RS485 mode, and enable
+// protocolExtensions, which the simulator answers with its feedback.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/simulator"
+)
+
+func main() {
+	config := simulator.DefaultConfig()
+	var link string
+	var faultCode uint
+	flag.StringVar(&link, "link", "", "create a symlink to the pseudo-terminal, e.g. /tmp/ttyBOW")
+	flag.Float64Var(&config.SlewRate, "slew", config.SlewRate, "cylinder speed in m/s")
+	flag.IntVar(&config.ReportRate, "rate", config.ReportRate, "position report rate in Hz")
+	flag.IntVar(&config.FaultAfter, "fault-after", 0, "raise a fault after this many set-positions frames, 0 never")
+	flag.UintVar(&faultCode, "fault-code", uint(simulator.FaultInjected), "fault code raised by -fault-after")
+	klog.InitFlags(nil)
+	flag.Parse()
+	defer klog.Flush()
+	config.FaultCode = byte(faultCode)
+
+	pty, err := simulator.OpenPTY()
+	if err != nil {
+		klog.Fatal(err)
+	}
+	defer pty.Close()
+	if link != "" {
+		os.Remove(link)
+		if err = os.Symlink(pty.SlaveName, link); err != nil {
+			klog.Fatal(err)
+		}
+		defer os.Remove(link)
+	}
+	fmt.Println(pty.SlaveName)
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+		pty.Close()
+	}()
+
+	if err = simulator.New(config).Serve(pty.Master, stop); err != nil {
+		select {
+		case <-stop:
+		default:
+			klog.Error(err)
+		}
+	}
+}
@@ -33,9 +33,87 @@ type COMStruct struct {
 	StopBits   int64  `json:"stopBits"`
 }
 
+// RS485Config is the RTS toggling of an RS485 transceiver, read from the
+// "rs485" customized value. Delays are in milliseconds.
+type RS485Config struct {
+	RtsHighDuringSend  bool `json:"rtsHighDuringSend,omitempty"`
+	RtsHighAfterSend   bool `json:"rtsHighAfterSend,omitempty"`
+	RxDuringTx         bool `json:"rxDuringTx,omitempty"`
+	DelayRtsBeforeSend int  `json:"delayRtsBeforeSend,omitempty"`
+	DelayRtsAfterSend  int  `json:"delayRtsAfterSend,omitempty"`
+}
+
+// KinematicsConfig is the platform geometry, read from the "kinematics"
+// customized value. There is no default geometry: the go and compare solvers
+// need the measured joints, the cgo solver has its own.
+type KinematicsConfig struct {
+	Solver         string       `json:"solver,omitempty"`
+	BaseJoints     [][3]float64 `json:"baseJoints,omitempty"`
+	PlatformJoints [][3]float64 `json:"platformJoints,omitempty"`
+	LegLength      float64      `json:"legLength,omitempty"`
+	HomeHeight     float64      `json:"homeHeight,omitempty"`
+	HomeStroke     float64      `json:"homeStroke,omitempty"`
+}
+
+// LimitsConfig is the platform workspace, read from the "limits" customized
+// value. Strokes hold either one value for every cylinder or one per cylinder.
+type LimitsConfig struct {
+	MinStroke      []float64 `json:"minStroke,omitempty"`
+	MaxStroke      []float64 `json:"maxStroke,omitempty"`
+	MaxTilt        float64   `json:"maxTilt,omitempty"`
+	MaxTranslation float64   `json:"maxTranslation,omitempty"`
+}
+
+// CylindersConfig is the calibration of the cylinder encoders, read from the
+// "cylinders" customized value. Values hold either one value for every
+// cylinder or one per cylinder; File stores the calibrated offsets.
+type CylindersConfig struct {
+	Offset    []float64 `json:"offset,omitempty"`
+	Scale     []float64 `json:"scale,omitempty"`
+	Invert    []bool    `json:"invert,omitempty"`
+	MinLength []float64 `json:"minLength,omitempty"`
+	MaxLength []float64 `json:"maxLength,omitempty"`
+	File      string    `json:"file,omitempty"`
+}
+
+// HomingConfig is the homing sequence, read from the "homing" customized
+// value. Durations are strings such as "5s".
+type HomingConfig struct {
+	OnStart          bool    `json:"onStart,omitempty"`
+	Ramp             string  `json:"ramp,omitempty"`
+	Timeout          string  `json:"timeout,omitempty"`
+	HandshakeTimeout string  `json:"handshakeTimeout,omitempty"`
+	Tolerance        float64 `json:"tolerance,omitempty"`
+}
+
+// MotionLimitsConfig is the trajectory profiling, read from the "motionLimits"
+// customized value. Limits hold one value for every axis or one per axis in
+// the order roll, pitch, yaw, x, y, z.
+type MotionLimitsConfig struct {
+	Mode         string    `json:"mode,omitempty"`
+	Velocity     []float64 `json:"velocity,omitempty"`
+	Acceleration []float64 `json:"acceleration,omitempty"`
+	Jerk         []float64 `json:"jerk,omitempty"`
+}
+
+// FrameConfig is the tracker to bow transform, read from the "frame"
+// customized value. The rotation is either a 3x3 matrix or an axis and an
+// angle in degrees.
+type FrameConfig struct {
+	Rotation      [][]float64 `json:"rotation,omitempty"`
+	RotationAxis  []float64   `json:"rotationAxis,omitempty"`
+	RotationAngle float64     `json:"rotationAngle,omitempty"`
+	IncisalPoint  []float64   `json:"incisalPoint,omitempty"`
+	LengthUnit    string      `json:"lengthUnit,omitempty"`
+}
+
 type DownloadRequest struct {
 	Path    string `json:"path"`
 	Segment string `json:"segment"`
+	// Orthonormalize repairs drifted rotations instead of refusing the segment.
+	Orthonormalize bool `json:"orthonormalize,omitempty"`
+	// Composition overrides the composition mode of the track.
+	Composition string `json:"composition,omitempty"`
 }
 
 type ExecuteRequest struct {
@@ -43,4 +121,56 @@ type ExecuteRequest struct {
 	Random  bool      `json:"random"`
 	Input   []float32 `json:"input"`
 	Period  int       `json:"period"`
+	// Rate resamples the segment to this rate in Hz before playback.
+	Rate int `json:"rate,omitempty"`
+	// Speed multiplies the playback rate, from 0.25 to 2.
+	Speed float64 `json:"speed,omitempty"`
+	// Loops repeats the playback, Continuous until it is stopped.
+	Loops      int  `json:"loops,omitempty"`
+	Continuous bool `json:"continuous,omitempty"`
+	// PingPong plays the segment forth and back, Reverse backwards.
+	PingPong bool `json:"pingPong,omitempty"`
+	Reverse  bool `json:"reverse,omitempty"`
+	// Start and End select the frames [Start, End) of the segment.
+	Start int `json:"start,omitempty"`
+	End   int `json:"end,omitempty"`
+}
+
+// StopRequest tells why and by whom an execution is stopped.
+type StopRequest struct {
+	Reason string `json:"reason"`
+	By     string `json:"by,omitempty"`
+}
+
+// LockRequest tells why and by whom the device is locked or unlocked.
+type LockRequest struct {
+	Reason string `json:"reason"`
+	By     string `json:"by,omitempty"`
+}
+
+// SeekRequest moves the running playback to a frame index or to a track time
+// in seconds; exactly one is set.
+type SeekRequest struct {
+	Frame *int     `json:"frame,omitempty"`
+	Time  *float64 `json:"time,omitempty"`
+}
+
+// CalibrateFrameRequest is the reference points of a frame calibration. The
+// calibration is refused when its RMS residual exceeds MaxResidual.
+type CalibrateFrameRequest struct {
+	Points      []PointPair `json:"points"`
+	LengthUnit  string      `json:"lengthUnit,omitempty"`
+	MaxResidual float64     `json:"maxResidual,omitempty"`
+}
+
+// CalibrateCylindersRequest is the measured length in meters of every
+// cylinder at encoder count zero.
+type CalibrateCylindersRequest struct {
+	Offsets []float64 `json:"offsets"`
+}
+
+// PointPair is one reference point measured in the tracker and platform frames.
+type PointPair struct {
+	Tracker  [3]float64 `json:"tracker"`
+	Platform [3]float64 `json:"platform"`
 }
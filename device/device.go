@@ -31,7 +31,10 @@ import (
 	"github.com/kubeedge/mappers-go/mappers/common"
 	"github.com/smilelinkd/digitalbow-mapper/configmap"
 	"github.com/smilelinkd/digitalbow-mapper/driver"
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
 	"github.com/smilelinkd/digitalbow-mapper/globals"
+	mappercommon "github.com/smilelinkd/digitalbow-mapper/pkg/common"
 )
 
 var devices map[string]*globals.ModbusDev
@@ -116,6 +119,79 @@ func onMessage(client mqtt.Client, message mqtt.Message) {
 	}
 }
 
+var commandTopic = regexp.MustCompile(`events/device/(.+)/command/([^/]+)$`)
+
+// onCommand callback function of the device command topics.
+func onCommand(client mqtt.Client, message mqtt.Message) {
+	klog.V(2).Info("Receive command ", message.Topic())
+	match := commandTopic.FindStringSubmatch(message.Topic())
+	if match == nil {
+		klog.Error("Wrong topic")
+		return
+	}
+	dev, ok := devices[match[1]]
+	if !ok || dev.DigitalbowClient == nil {
+		klog.Error("Device not exist")
+		return
+	}
+
+	switch match[2] {
+	case mappercommon.CommandStop:
+		var stopRequest configmap.StopRequest
+		if err := json.Unmarshal(message.Payload(), &stopRequest); err != nil {
+			klog.Errorf("Unmarshal stop command failed: %v", err)
+			return
+		}
+		if stopRequest.By == "" {
+			stopRequest.By = "mqtt"
+		}
+		if _, err := dev.DigitalbowClient.Stop(stopRequest.Reason, stopRequest.By); err != nil {
+			klog.Errorf("Stop command failed: %v", err)
+		}
+	case mappercommon.CommandPause:
+		if err := dev.DigitalbowClient.Pause(); err != nil {
+			klog.Errorf("Pause command failed: %v", err)
+		}
+	case mappercommon.CommandResume:
+		if err := dev.DigitalbowClient.Resume(); err != nil {
+			klog.Errorf("Resume command failed: %v", err)
+		}
+	case mappercommon.CommandSeek:
+		var seekRequest configmap.SeekRequest
+		if err := json.Unmarshal(message.Payload(), &seekRequest); err != nil {
+			klog.Errorf("Unmarshal seek command failed: %v", err)
+			return
+		}
+		if err := dev.DigitalbowClient.SeekFrameOrTime(seekRequest.Frame, seekRequest.Time); err != nil {
+			klog.Errorf("Seek command failed: %v", err)
+		}
+	case mappercommon.CommandHome:
+		go func() {
+			if _, err := dev.DigitalbowClient.Home("home requested over MQTT"); err != nil {
+				klog.Errorf("Home command failed: %v", err)
+			}
+		}()
+	case mappercommon.CommandLock, mappercommon.CommandUnlock:
+		var lockRequest configmap.LockRequest
+		if err := json.Unmarshal(message.Payload(), &lockRequest); err != nil {
+			klog.Errorf("Unmarshal %s command failed: %v", match[2], err)
+			return
+		}
+		if lockRequest.By == "" {
+			lockRequest.By = "mqtt"
+		}
+		change := dev.DigitalbowClient.Lock
+		if match[2] == mappercommon.CommandUnlock {
+			change = dev.DigitalbowClient.Unlock
+		}
+		if err := change(lockRequest.Reason, lockRequest.By); err != nil {
+			klog.Errorf("%s command failed: %v", match[2], err)
+		}
+	default:
+		klog.Errorf("Unknown command %s", match[2])
+	}
+}
+
 // isRS485Enabled is RS485 feature enabled for RTU.
 func isRS485Enabled(customizedValue configmap.CustomizedValue) bool {
 	isEnabled := false
@@ -130,9 +206,318 @@ func isRS485Enabled(customizedValue configmap.CustomizedValue) bool {
 	return isEnabled
 }
 
+// durationValue returns the customized value of key, a duration such as
+// "500ms", or fallback when it is not configured.
+func durationValue(customizedValue configmap.CustomizedValue, key string, fallback time.Duration) (time.Duration, error) {
+	var value string
+	configured, err := decodeCustomizedValue(customizedValue, key, &value)
+	if err != nil || !configured {
+		return fallback, err
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid customized value %s: %v", key, err)
+	}
+	return duration, nil
+}
+
+// decodeCustomizedValue decode the customized value of key into out.
+// It returns false if the key is not configured.
+func decodeCustomizedValue(customizedValue configmap.CustomizedValue, key string, out interface{}) (bool, error) {
+	value, ok := customizedValue[key]
+	if !ok {
+		return false, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return true, err
+	}
+	if err = json.Unmarshal(data, out); err != nil {
+		return true, fmt.Errorf("invalid customized value %s: %v", key, err)
+	}
+	return true, nil
+}
+
+// kinematicsGeometry build the platform geometry from the kinematics config.
+// It is zero when no joints are configured.
+func kinematicsGeometry(config configmap.KinematicsConfig) (kinematics.Geometry, error) {
+	var geometry kinematics.Geometry
+	if len(config.BaseJoints) == 0 && len(config.PlatformJoints) == 0 {
+		return geometry, nil
+	}
+	if len(config.BaseJoints) != 0 {
+		if len(config.BaseJoints) != kinematics.Legs {
+			return geometry, fmt.Errorf("expect %d base joints, got %d", kinematics.Legs, len(config.BaseJoints))
+		}
+		for i, joint := range config.BaseJoints {
+			geometry.BaseJoints[i] = joint
+		}
+	}
+	if len(config.PlatformJoints) != 0 {
+		if len(config.PlatformJoints) != kinematics.Legs {
+			return geometry, fmt.Errorf("expect %d platform joints, got %d", kinematics.Legs, len(config.PlatformJoints))
+		}
+		for i, joint := range config.PlatformJoints {
+			geometry.PlatformJoints[i] = joint
+		}
+	}
+	if config.LegLength != 0 {
+		geometry.LegLength = config.LegLength
+	}
+	if config.HomeHeight != 0 {
+		geometry.HomeHeight = config.HomeHeight
+	}
+	geometry.HomeStroke = protocol.StrokeZero
+	if config.HomeStroke != 0 {
+		geometry.HomeStroke = config.HomeStroke
+	}
+	return geometry, nil
+}
+
+// expandValues expand one value for every axis or one value per axis.
+func expandValues(name string, values []float64) (result [6]float64, err error) {
+	switch len(values) {
+	case 0:
+	case 1:
+		for i := range result {
+			result[i] = values[0]
+		}
+	case len(result):
+		copy(result[:], values)
+	default:
+		err = fmt.Errorf("expect 1 or %d %s values, got %d", len(result), name, len(values))
+	}
+	return result, err
+}
+
+// kinematicsLimits build the platform limits from the limits config.
+func kinematicsLimits(config configmap.LimitsConfig) (limits kinematics.Limits, err error) {
+	if limits.MinStroke, err = expandValues("minStroke", config.MinStroke); err != nil {
+		return limits, err
+	}
+	if limits.MaxStroke, err = expandValues("maxStroke", config.MaxStroke); err != nil {
+		return limits, err
+	}
+	for i := range limits.MinStroke {
+		if limits.MinStroke[i] > limits.MaxStroke[i] {
+			return limits, fmt.Errorf("cylinder %d min stroke is above max stroke", i+1)
+		}
+	}
+	limits.MaxTilt = config.MaxTilt
+	limits.MaxTranslation = config.MaxTranslation
+	return limits, nil
+}
+
+// cylinderCalibration build the cylinder calibration from the cylinders
+// config; unset values keep the nominal calibration.
+func cylinderCalibration(config configmap.CylindersConfig) (driver.Calibration, error) {
+	calibration := driver.DefaultCalibration()
+	values := []struct {
+		name   string
+		config []float64
+		set    func(cylinder *driver.CylinderCalibration, value float64)
+	}{
+		{"offset", config.Offset, func(cylinder *driver.CylinderCalibration, value float64) { cylinder.Offset = value }},
+		{"scale", config.Scale, func(cylinder *driver.CylinderCalibration, value float64) { cylinder.Scale = value }},
+		{"minLength", config.MinLength, func(cylinder *driver.CylinderCalibration, value float64) { cylinder.MinLength = value }},
+		{"maxLength", config.MaxLength, func(cylinder *driver.CylinderCalibration, value float64) { cylinder.MaxLength = value }},
+	}
+	for _, value := range values {
+		if len(value.config) == 0 {
+			continue
+		}
+		expanded, err := expandValues(value.name, value.config)
+		if err != nil {
+			return calibration, err
+		}
+		for i := range calibration {
+			value.set(&calibration[i], expanded[i])
+		}
+	}
+	switch len(config.Invert) {
+	case 0:
+	case 1:
+		for i := range calibration {
+			calibration[i].Invert = config.Invert[0]
+		}
+	case len(calibration):
+		for i := range calibration {
+			calibration[i].Invert = config.Invert[i]
+		}
+	default:
+		return calibration, fmt.Errorf("expect 1 or %d invert values, got %d", len(calibration), len(config.Invert))
+	}
+	return calibration, calibration.Validate()
+}
+
+// homingConfig build the homing sequence from the homing config.
+func homingConfig(config configmap.HomingConfig) (homing driver.HomingConfig, err error) {
+	homing.OnStart = config.OnStart
+	homing.Tolerance = config.Tolerance
+	durations := []struct {
+		name  string
+		value string
+		out   *time.Duration
+	}{
+		{"ramp", config.Ramp, &homing.Ramp},
+		{"timeout", config.Timeout, &homing.Timeout},
+		{"handshakeTimeout", config.HandshakeTimeout, &homing.HandshakeTimeout},
+	}
+	for _, duration := range durations {
+		if duration.value == "" {
+			continue
+		}
+		if *duration.out, err = time.ParseDuration(duration.value); err != nil {
+			return homing, fmt.Errorf("invalid homing %s: %v", duration.name, err)
+		}
+	}
+	return homing, nil
+}
+
+// motionLimits build the trajectory limits from the motion limits config.
+func motionLimits(config configmap.MotionLimitsConfig) (limits driver.MotionLimits, err error) {
+	limits.Mode = config.Mode
+	if limits.Velocity, err = expandValues("velocity", config.Velocity); err != nil {
+		return limits, err
+	}
+	if limits.Acceleration, err = expandValues("acceleration", config.Acceleration); err != nil {
+		return limits, err
+	}
+	if limits.Jerk, err = expandValues("jerk", config.Jerk); err != nil {
+		return limits, err
+	}
+	return limits, nil
+}
+
+// frameConfig build the tracker to bow transform from the frame config.
+func frameConfig(config configmap.FrameConfig, configured bool) (driver.FrameConfig, error) {
+	frame := driver.DefaultFrameConfig()
+	if !configured {
+		return frame, nil
+	}
+	frame.Source = "config"
+	switch {
+	case len(config.Rotation) != 0 && len(config.RotationAxis) != 0:
+		return frame, errors.New("frame rotation and rotation axis are exclusive")
+	case len(config.Rotation) != 0:
+		if len(config.Rotation) != 3 {
+			return frame, fmt.Errorf("expect 3 frame rotation rows, got %d", len(config.Rotation))
+		}
+		for i, row := range config.Rotation {
+			if len(row) != 3 {
+				return frame, fmt.Errorf("expect 3 values in frame rotation row %d, got %d", i, len(row))
+			}
+			copy(frame.Rotation[i][:], row)
+		}
+	case len(config.RotationAxis) != 0:
+		if len(config.RotationAxis) != 3 {
+			return frame, fmt.Errorf("expect 3 frame rotation axis values, got %d", len(config.RotationAxis))
+		}
+		var axis [3]float64
+		copy(axis[:], config.RotationAxis)
+		frame.Rotation = driver.AxisAngleRotation(axis, config.RotationAngle)
+	}
+	if len(config.IncisalPoint) != 0 {
+		if len(config.IncisalPoint) != 3 {
+			return frame, fmt.Errorf("expect 3 incisal point values, got %d", len(config.IncisalPoint))
+		}
+		copy(frame.IncisalPoint[:], config.IncisalPoint)
+	}
+	if config.LengthUnit != "" {
+		frame.LengthUnit = config.LengthUnit
+	}
+	return frame, frame.Validate()
+}
+
 // initBow initialize bow client
 func initBow(protocolConfig configmap.BowProtocolCommonConfig) (client *driver.DigitalbowClient, err error) {
 	if protocolConfig.COM.SerialPort != "" {
+		var kinematicsConfig configmap.KinematicsConfig
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "kinematics", &kinematicsConfig); err != nil {
+			return nil, err
+		}
+		geometry, err := kinematicsGeometry(kinematicsConfig)
+		if err != nil {
+			return nil, err
+		}
+		var limitsConfig configmap.LimitsConfig
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "limits", &limitsConfig); err != nil {
+			return nil, err
+		}
+		limits, err := kinematicsLimits(limitsConfig)
+		if err != nil {
+			return nil, err
+		}
+		var motionLimitsConfig configmap.MotionLimitsConfig
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "motionLimits", &motionLimitsConfig); err != nil {
+			return nil, err
+		}
+		motion, err := motionLimits(motionLimitsConfig)
+		if err != nil {
+			return nil, err
+		}
+		var frameCfg configmap.FrameConfig
+		configured, err := decodeCustomizedValue(protocolConfig.CustomizedValues, "frame", &frameCfg)
+		if err != nil {
+			return nil, err
+		}
+		frame, err := frameConfig(frameCfg, configured)
+		if err != nil {
+			return nil, err
+		}
+		var playbackRate int
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "playbackRate", &playbackRate); err != nil {
+			return nil, err
+		}
+		var eulerOrder string
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "eulerOrder", &eulerOrder); err != nil {
+			return nil, err
+		}
+		var composition string
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "composition", &composition); err != nil {
+			return nil, err
+		}
+		var rs485Config configmap.RS485Config
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "rs485", &rs485Config); err != nil {
+			return nil, err
+		}
+		timeout, err := durationValue(protocolConfig.CustomizedValues, "timeout", 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		stopRamp, err := durationValue(protocolConfig.CustomizedValues, "stopRamp", driver.DefaultStopRamp)
+		if err != nil {
+			return nil, err
+		}
+		seekTransition, err := durationValue(protocolConfig.CustomizedValues, "seekTransition", driver.DefaultSeekTransition)
+		if err != nil {
+			return nil, err
+		}
+		var cylindersConfig configmap.CylindersConfig
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "cylinders", &cylindersConfig); err != nil {
+			return nil, err
+		}
+		calibration, err := cylinderCalibration(cylindersConfig)
+		if err != nil {
+			return nil, err
+		}
+		var homingCfg configmap.HomingConfig
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "homing", &homingCfg); err != nil {
+			return nil, err
+		}
+		homing, err := homingConfig(homingCfg)
+		if err != nil {
+			return nil, err
+		}
+		var protocolExtensions bool
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "protocolExtensions", &protocolExtensions); err != nil {
+			return nil, err
+		}
+		var capturePath string
+		if _, err = decodeCustomizedValue(protocolConfig.CustomizedValues, "capture", &capturePath); err != nil {
+			return nil, err
+		}
+
 		RTUConfig := driver.BowRTUConfig{
 			SerialName:   protocolConfig.COM.SerialPort,
 			BaudRate:     int(protocolConfig.COM.BaudRate),
@@ -140,9 +525,34 @@ func initBow(protocolConfig configmap.BowProtocolCommonConfig) (client *driver.D
 			StopBits:     int(protocolConfig.COM.StopBits),
 			Parity:       protocolConfig.COM.Parity,
 			RS485Enabled: isRS485Enabled(protocolConfig.CustomizedValues),
-			Timeout:      5 * time.Second}
-
-		client, _ = driver.NewClient(RTUConfig)
+			RS485: driver.RS485Config{
+				RtsHighDuringSend:  rs485Config.RtsHighDuringSend,
+				RtsHighAfterSend:   rs485Config.RtsHighAfterSend,
+				RxDuringTx:         rs485Config.RxDuringTx,
+				DelayRtsBeforeSend: rs485Config.DelayRtsBeforeSend,
+				DelayRtsAfterSend:  rs485Config.DelayRtsAfterSend,
+			},
+			Timeout:            timeout,
+			Solver:             kinematicsConfig.Solver,
+			Geometry:           geometry,
+			Limits:             limits,
+			PlaybackRate:       playbackRate,
+			MotionLimits:       motion,
+			Frame:              frame,
+			EulerOrder:         eulerOrder,
+			Composition:        composition,
+			Capture:            capturePath,
+			StopRamp:           stopRamp,
+			SeekTransition:     seekTransition,
+			Homing:             homing,
+			ProtocolExtensions: protocolExtensions,
+			Calibration:        calibration,
+			CalibrationFile:    cylindersConfig.File}
+
+		client, err = driver.NewClient(RTUConfig)
+		if err != nil {
+			return nil, err
+		}
 		client.Client.Init()
 	} else {
 		return nil, errors.New("No protocol found")
@@ -202,7 +612,12 @@ func initData(dev *globals.ModbusDev) {
 func initSubscribeMqtt(instanceID string) error {
 	topic := fmt.Sprintf(common.TopicTwinUpdateDelta, instanceID)
 	klog.V(1).Info("Subscribe topic: ", topic)
-	return globals.MqttClient.Subscribe(topic, onMessage)
+	if err := globals.MqttClient.Subscribe(topic, onMessage); err != nil {
+		return err
+	}
+	topic = fmt.Sprintf(mappercommon.TopicCommand, instanceID, "+")
+	klog.V(1).Info("Subscribe topic: ", topic)
+	return globals.MqttClient.Subscribe(topic, onCommand)
 }
 
 // initGetStatus start timer to get device status and send to eventbus.
@@ -217,6 +632,30 @@ func initGetStatus(dev *globals.ModbusDev) {
 	}()
 }
 
+// dataPublisher publish the device data to the data update topic.
+func dataPublisher(instanceID string) driver.DataPublisher {
+	topic := fmt.Sprintf(common.TopicDataUpdate, instanceID)
+	return func(name string, valueType string, value string) error {
+		payload, err := common.CreateMessageData(name, valueType, value)
+		if err != nil {
+			return err
+		}
+		return globals.MqttClient.Publish(topic, payload)
+	}
+}
+
+// twinPublisher publish the device twin to the twin update topic.
+func twinPublisher(instanceID string) driver.DataPublisher {
+	topic := fmt.Sprintf(common.TopicTwinUpdate, instanceID)
+	return func(name string, valueType string, value string) error {
+		payload, err := common.CreateMessageTwinUpdate(name, valueType, value)
+		if err != nil {
+			return err
+		}
+		return globals.MqttClient.Publish(topic, payload)
+	}
+}
+
 // start the device.
 func start(dev *globals.ModbusDev) {
 	var protocolCommConfig configmap.BowProtocolCommonConfig
@@ -231,6 +670,12 @@ func start(dev *globals.ModbusDev) {
 		return
 	}
 	dev.DigitalbowClient = client
+	client.SetDataPublisher(dataPublisher(dev.Instance.ID))
+	client.SetTwinPublisher(twinPublisher(dev.Instance.ID))
+	if err = client.Connect(); err != nil {
+		klog.Errorf("Serial port error: %v", err)
+		return
+	}
 
 	initTwin(dev)
 	initData(dev)
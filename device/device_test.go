@@ -0,0 +1,129 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/configmap"
+	"github.com/smilelinkd/digitalbow-mapper/driver"
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+	"github.com/smilelinkd/digitalbow-mapper/globals"
+	mappercommon "github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// commandMessage is a message received on a command topic.
+type commandMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m commandMessage) Duplicate() bool   { return false }
+func (m commandMessage) Qos() byte         { return 0 }
+func (m commandMessage) Retained() bool    { return false }
+func (m commandMessage) Topic() string     { return m.topic }
+func (m commandMessage) MessageID() uint16 { return 0 }
+func (m commandMessage) Payload() []byte   { return m.payload }
+func (m commandMessage) Ack()              {}
+
+// sendCommand delivers the command of the device with payload encoded as
+// JSON.
+func sendCommand(t *testing.T, id, command string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	onCommand(nil, commandMessage{topic: fmt.Sprintf(mappercommon.TopicCommand, id, command), payload: data})
+}
+
+// ports counts the clients of the tests. The driver keeps one client per
+// serial port, so every client gets a port of its own.
+var ports int
+
+func testPort(t *testing.T) string {
+	ports++
+	return fmt.Sprintf("%s-%d", t.Name(), ports)
+}
+
+// newCommandDevice registers a Ready device with ID id for the test.
+func newCommandDevice(t *testing.T, id string) *driver.DigitalbowClient {
+	client, err := driver.NewClient(driver.BowRTUConfig{
+		SerialName: testPort(t),
+		Geometry: kinematics.Geometry{
+			BaseJoints:     kinematics.HexagonJoints(0.25, 15),
+			PlatformJoints: kinematics.HexagonJoints(0.15, 45),
+			LegLength:      0.4,
+			HomeStroke:     0.1569,
+		},
+		Frame: driver.DefaultFrameConfig(),
+	})
+	require.NoError(t, err)
+	client.Status = mappercommon.StatusReady
+	previous := devices
+	devices = map[string]*globals.ModbusDev{id: {DigitalbowClient: client}}
+	t.Cleanup(func() { devices = previous })
+	return client
+}
+
+func TestOnCommandLock(t *testing.T) {
+	client := newCommandDevice(t, "bow")
+
+	sendCommand(t, "bow", mappercommon.CommandLock, configmap.LockRequest{Reason: "maintenance"})
+	assert.Equal(t, mappercommon.StatusLocked, client.GetStatus())
+	history := client.StatusHistory()
+	assert.Contains(t, history[len(history)-1].Reason, "locked by mqtt: maintenance")
+
+	// Commands of other devices, malformed payloads, unknown commands and
+	// other topics are ignored.
+	sendCommand(t, "other", mappercommon.CommandUnlock, configmap.LockRequest{})
+	onCommand(nil, commandMessage{topic: fmt.Sprintf(mappercommon.TopicCommand, "bow", mappercommon.CommandUnlock),
+		payload: []byte("{")})
+	sendCommand(t, "bow", "dance", nil)
+	onCommand(nil, commandMessage{topic: fmt.Sprintf(mappercommon.TopicDataUpdate, "bow")})
+	assert.Equal(t, mappercommon.StatusLocked, client.GetStatus())
+
+	sendCommand(t, "bow", mappercommon.CommandUnlock, configmap.LockRequest{Reason: "done", By: "operator"})
+	assert.Equal(t, mappercommon.StatusReady, client.GetStatus())
+	history = client.StatusHistory()
+	assert.Contains(t, history[len(history)-1].Reason, "unlocked by operator: done")
+}
+
+func TestOnCommandPlayback(t *testing.T) {
+	client := newCommandDevice(t, "bow")
+
+	// Without an execution the commands are refused and change nothing.
+	sendCommand(t, "bow", mappercommon.CommandPause, nil)
+	sendCommand(t, "bow", mappercommon.CommandStop, configmap.StopRequest{Reason: "test"})
+	assert.Equal(t, mappercommon.StatusReady, client.GetStatus())
+	assert.Nil(t, client.ExecutionStatus().LastStop)
+
+	job, err := client.SubmitJob(driver.JobSpec{Segment: "chewing", Frames: 10, Frequency: 10,
+		Run: func(ctx context.Context, control *driver.PlaybackControl) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return client.GetStatus() == mappercommon.StatusExecucting },
+		time.Second, time.Millisecond)
+
+	sendCommand(t, "bow", mappercommon.CommandPause, nil)
+	assert.Equal(t, mappercommon.StatusPaused, client.GetStatus())
+	frame := 4
+	sendCommand(t, "bow", mappercommon.CommandSeek, configmap.SeekRequest{Frame: &frame})
+	sendCommand(t, "bow", mappercommon.CommandResume, nil)
+	assert.Equal(t, mappercommon.StatusExecucting, client.GetStatus())
+
+	sendCommand(t, "bow", mappercommon.CommandStop, configmap.StopRequest{Reason: "test"})
+	require.Eventually(t, func() bool {
+		job, err = client.Job(job.ID)
+		require.NoError(t, err)
+		return job.State == driver.JobStopped
+	}, time.Second, time.Millisecond)
+	require.NotNil(t, job.Stop)
+	assert.Equal(t, "mqtt", job.Stop.By)
+	assert.Equal(t, "test", job.Stop.Reason)
+	assert.Equal(t, mappercommon.StatusReady, client.GetStatus())
+}
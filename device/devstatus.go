@@ -22,6 +22,7 @@ import (
 	"github.com/kubeedge/mappers-go/mappers/common"
 	"github.com/smilelinkd/digitalbow-mapper/driver"
 	"github.com/smilelinkd/digitalbow-mapper/globals"
+	mappercommon "github.com/smilelinkd/digitalbow-mapper/pkg/common"
 )
 
 // GetStatus is the timer structure for getting device status.
@@ -45,4 +46,11 @@ func (gs *GetStatus) Run() {
 		klog.Errorf("Publish failed: %v", err)
 		return
 	}
+
+	// Report the progress of a running execution.
+	if execution := gs.Client.ExecutionStatus(); execution.Frames != 0 {
+		if err = gs.Client.PublishData(mappercommon.DataExecution, execution); err != nil {
+			klog.Errorf("Publish execution status failed: %v", err)
+		}
+	}
 }
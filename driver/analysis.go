@@ -0,0 +1,143 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// minCondylarTravel is the condyle travel in mm below which its path angles
+// are not defined.
+const minCondylarTravel = 0.5
+
+// PathAnalysis is the clinical analysis of the incisal and condylar paths of
+// a segment. Points are rotated into the bow frame, where X is lateral
+// (positive to the patient's left), Y anterior and Z superior, and measured
+// from the first frame. Distances are in mm and angles in degrees; angles are
+// zero when the condyle did not travel.
+type PathAnalysis struct {
+	Segment string `json:"segment"`
+	Frames  int    `json:"frames"`
+	// MaxOpening is the largest inferior travel of the incisal point.
+	MaxOpening float64 `json:"maxOpening"`
+	// Protrusion is the largest anterior travel of the incisal point.
+	Protrusion float64 `json:"protrusion"`
+	// LateralLeft and LateralRight are the largest lateral travels of the
+	// incisal point.
+	LateralLeft  float64 `json:"lateralLeft"`
+	LateralRight float64 `json:"lateralRight"`
+	// CondylarInclination is the angle below horizontal of the sagittal
+	// condylar path at the condyle's most anterior position.
+	CondylarInclinationLeft  float64 `json:"condylarInclinationLeft"`
+	CondylarInclinationRight float64 `json:"condylarInclinationRight"`
+	// BennettAngle is the angle between the sagittal plane and the path of the
+	// orbiting condyle in the horizontal plane: the left condyle during a
+	// right excursion and the other way round.
+	BennettAngleLeft  float64 `json:"bennettAngleLeft"`
+	BennettAngleRight float64 `json:"bennettAngleRight"`
+}
+
+// AnalyzeSegment computes the path analysis of a downloaded segment.
+func (c *DigitalbowClient) AnalyzeSegment(segment string) (PathAnalysis, error) {
+	trackData, ok := c.Movement(segment)
+	if !ok {
+		return PathAnalysis{}, fmt.Errorf("segment %s does not exist", segment)
+	}
+	frame := c.Frame()
+	scale, err := lengthScale(frame.LengthUnit)
+	if err != nil {
+		return PathAnalysis{}, err
+	}
+	analysis, err := AnalyzePaths(trackData, frame.Rotation, scale*1000)
+	analysis.Segment = segment
+	return analysis, err
+}
+
+// AnalyzePaths computes the path analysis of a track. The points are rotated
+// by rotation and multiplied by toMillimeter.
+func AnalyzePaths(trackData TrackData, rotation [3][3]float64, toMillimeter float64) (PathAnalysis, error) {
+	analysis := PathAnalysis{Frames: len(trackData.IPList)}
+	if len(trackData.IPList) < 2 {
+		return analysis, errors.New("track has no incisal path")
+	}
+
+	ip := displacements(trackData.IPList, rotation, toMillimeter)
+	for _, d := range ip {
+		analysis.MaxOpening = math.Max(analysis.MaxOpening, -d[2])
+		analysis.Protrusion = math.Max(analysis.Protrusion, d[1])
+		analysis.LateralLeft = math.Max(analysis.LateralLeft, d[0])
+		analysis.LateralRight = math.Max(analysis.LateralRight, -d[0])
+	}
+
+	rightExcursion, leftExcursion := extremeFrame(ip, 0, -1), extremeFrame(ip, 0, 1)
+	if len(trackData.LCList) == len(trackData.IPList) {
+		lc := displacements(trackData.LCList, rotation, toMillimeter)
+		analysis.CondylarInclinationLeft = condylarInclination(lc)
+		analysis.BennettAngleLeft = bennettAngle(lc[rightExcursion])
+	}
+	if len(trackData.RCList) == len(trackData.IPList) {
+		rc := displacements(trackData.RCList, rotation, toMillimeter)
+		analysis.CondylarInclinationRight = condylarInclination(rc)
+		analysis.BennettAngleRight = bennettAngle(rc[leftExcursion])
+	}
+	return analysis, nil
+}
+
+// displacements rotates every point and returns its offset from the first.
+func displacements(points [][3]float64, rotation [3][3]float64, toMillimeter float64) [][3]float64 {
+	result := make([][3]float64, len(points))
+	for i, point := range points {
+		for row := 0; row < 3; row++ {
+			for k := 0; k < 3; k++ {
+				result[i][row] += rotation[row][k] * (point[k] - points[0][k]) * toMillimeter
+			}
+		}
+	}
+	return result
+}
+
+// extremeFrame returns the frame with the largest sign*d[axis].
+func extremeFrame(d [][3]float64, axis int, sign float64) int {
+	best := 0
+	for i := range d {
+		if sign*d[i][axis] > sign*d[best][axis] {
+			best = i
+		}
+	}
+	return best
+}
+
+// condylarInclination returns the sagittal path angle below horizontal at the
+// condyle's most anterior position.
+func condylarInclination(condyle [][3]float64) float64 {
+	d := condyle[extremeFrame(condyle, 1, 1)]
+	if math.Hypot(d[1], d[2]) < minCondylarTravel {
+		return 0
+	}
+	return math.Atan2(-d[2], d[1]) * 180 / math.Pi
+}
+
+// bennettAngle returns the horizontal angle between the sagittal plane and
+// the condyle displacement.
+func bennettAngle(d [3]float64) float64 {
+	if math.Hypot(d[0], d[1]) < minCondylarTravel {
+		return 0
+	}
+	return math.Atan2(math.Abs(d[0]), d[1]) * 180 / math.Pi
+}
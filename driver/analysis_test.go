@@ -0,0 +1,69 @@
+package driver
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var identityRotation = [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+func TestAnalyzePaths(t *testing.T) {
+	tan30 := math.Tan(30 * math.Pi / 180)
+	tan15 := math.Tan(15 * math.Pi / 180)
+	track := TrackData{
+		// rest, opening, protrusion, right excursion, left excursion
+		IPList: [][3]float64{{0, 0, 0}, {0, -5, -40}, {0, 8, -2}, {-10, 1, -1}, {9, 1, -1}},
+		// The left condyle slides 8 mm forward 30° down on protrusion and
+		// orbits 15° medially on the right excursion.
+		LCList: [][3]float64{{0, 0, 0}, {0, 4, -4 * tan30}, {0, 8, -8 * tan30}, {-4 * tan15, 4, 0}, {0, 0, 0}},
+		RCList: [][3]float64{{0, 0, 0}, {0, 4, -2}, {0, 8, -4}, {0, 0, 0}, {2, 4, 0}},
+	}
+
+	analysis, err := AnalyzePaths(track, identityRotation, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, analysis.Frames)
+	assert.InDelta(t, 40, analysis.MaxOpening, 1e-9)
+	assert.InDelta(t, 8, analysis.Protrusion, 1e-9)
+	assert.InDelta(t, 10, analysis.LateralRight, 1e-9)
+	assert.InDelta(t, 9, analysis.LateralLeft, 1e-9)
+	assert.InDelta(t, 30, analysis.CondylarInclinationLeft, 1e-9)
+	assert.InDelta(t, math.Atan(0.5)*180/math.Pi, analysis.CondylarInclinationRight, 1e-9)
+	assert.InDelta(t, 15, analysis.BennettAngleLeft, 1e-9)
+	assert.InDelta(t, math.Atan(0.5)*180/math.Pi, analysis.BennettAngleRight, 1e-9)
+
+	analysis, err = AnalyzePaths(track, identityRotation, 10)
+	assert.NoError(t, err)
+	assert.InDelta(t, 400, analysis.MaxOpening, 1e-9)
+
+	_, err = AnalyzePaths(TrackData{}, identityRotation, 1)
+	assert.Error(t, err)
+}
+
+func TestAnalyzeSegmentWhileDownloading(t *testing.T) {
+	client := newTestClient(t)
+	content, err := json.Marshal(validTrack())
+	require.NoError(t, err)
+
+	// Run with -race: downloads and analyses share the segments.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := client.LoadTrack("chewing", content, false, CompositionPremultiply)
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = client.AnalyzeSegment("chewing")
+		}()
+	}
+	wg.Wait()
+	_, err = client.AnalyzeSegment("chewing")
+	assert.NoError(t, err)
+}
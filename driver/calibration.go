@@ -0,0 +1,153 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+	"k8s.io/klog/v2"
+)
+
+// degenerateTolerance is the smallest second singular value of the point
+// cross covariance accepted as non collinear.
+const degenerateTolerance = 1e-9
+
+// PointPair is one reference point measured in the tracker frame and in the
+// platform frame, in the same length unit.
+type PointPair struct {
+	Tracker  [3]float64 `json:"tracker"`
+	Platform [3]float64 `json:"platform"`
+}
+
+// FrameCalibration is the result of a reference point calibration.
+type FrameCalibration struct {
+	Frame FrameReport `json:"frame"`
+	// Residuals is the distance between every mapped tracker point and its
+	// platform point.
+	Residuals   []float64 `json:"residuals"`
+	RMSResidual float64   `json:"rmsResidual"`
+	MaxResidual float64   `json:"maxResidual"`
+	Applied     bool      `json:"applied"`
+}
+
+// SolveRigidTransform returns the rotation and translation that best map the
+// tracker points onto the platform points in the least squares sense, with
+// the Kabsch algorithm.
+func SolveRigidTransform(pairs []PointPair) (rotation [3][3]float64, translation [3]float64, err error) {
+	if len(pairs) < 3 {
+		return rotation, translation, fmt.Errorf("need at least 3 point pairs, got %d", len(pairs))
+	}
+
+	var trackerCentroid, platformCentroid [3]float64
+	for _, pair := range pairs {
+		for k := 0; k < 3; k++ {
+			trackerCentroid[k] += pair.Tracker[k] / float64(len(pairs))
+			platformCentroid[k] += pair.Platform[k] / float64(len(pairs))
+		}
+	}
+
+	// Cross covariance H = sum (p - p̄)(q - q̄)ᵀ.
+	covariance := mat.NewDense(3, 3, nil)
+	for _, pair := range pairs {
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				covariance.Set(i, j, covariance.At(i, j)+
+					(pair.Tracker[i]-trackerCentroid[i])*(pair.Platform[j]-platformCentroid[j]))
+			}
+		}
+	}
+
+	var svd mat.SVD
+	if ok := svd.Factorize(covariance, mat.SVDFull); !ok {
+		return rotation, translation, errors.New("singular value decomposition failed")
+	}
+	if values := svd.Values(nil); values[1] < degenerateTolerance {
+		return rotation, translation, errors.New("reference points are collinear")
+	}
+	var u, v mat.Dense
+	svd.UTo(&u)
+	svd.VTo(&v)
+
+	// R = V diag(1, 1, d) Uᵀ, with d correcting a reflection into a rotation.
+	var vut mat.Dense
+	vut.Mul(&v, u.T())
+	correction := mat.NewDiagDense(3, []float64{1, 1, math.Copysign(1, mat.Det(&vut))})
+	var r, vc mat.Dense
+	vc.Mul(&v, correction)
+	r.Mul(&vc, u.T())
+
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			rotation[i][j] = r.At(i, j)
+		}
+		translation[i] = platformCentroid[i]
+		for j := 0; j < 3; j++ {
+			translation[i] -= rotation[i][j] * trackerCentroid[j]
+		}
+	}
+	return rotation, translation, nil
+}
+
+// CalibrateFrame solves the tracker to platform transform from the reference
+// points. The transform is applied as the client's Transform_AU and
+// Rotation_AU unless its RMS residual exceeds maxResidual (zero accepts any).
+func (c *DigitalbowClient) CalibrateFrame(pairs []PointPair, lengthUnit string, maxResidual float64) (FrameCalibration, error) {
+	var calibration FrameCalibration
+	rotation, translation, err := SolveRigidTransform(pairs)
+	if err != nil {
+		return calibration, err
+	}
+
+	frame := c.Frame().FrameConfig
+	frame.Rotation = rotation
+	frame.IncisalPoint = translation
+	if lengthUnit != "" {
+		frame.LengthUnit = lengthUnit
+	}
+	frame.Source = "calibration"
+
+	var sumSquares float64
+	for _, pair := range pairs {
+		var distance float64
+		for i := 0; i < 3; i++ {
+			mapped := translation[i]
+			for j := 0; j < 3; j++ {
+				mapped += rotation[i][j] * pair.Tracker[j]
+			}
+			distance += (mapped - pair.Platform[i]) * (mapped - pair.Platform[i])
+		}
+		distance = math.Sqrt(distance)
+		calibration.Residuals = append(calibration.Residuals, distance)
+		calibration.MaxResidual = math.Max(calibration.MaxResidual, distance)
+		sumSquares += distance * distance
+	}
+	calibration.RMSResidual = math.Sqrt(sumSquares / float64(len(pairs)))
+	calibration.Frame = FrameReport{FrameConfig: frame, Transform: frame.Transform()}
+
+	if maxResidual > 0 && calibration.RMSResidual > maxResidual {
+		return calibration, fmt.Errorf("calibration residual %v exceeds %v", calibration.RMSResidual, maxResidual)
+	}
+	if err = c.SetFrame(frame); err != nil {
+		return calibration, err
+	}
+	calibration.Applied = true
+	klog.V(1).Infof("Frame calibrated from %d points, rms residual %v", len(pairs), calibration.RMSResidual)
+	return calibration, nil
+}
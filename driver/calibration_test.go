@@ -0,0 +1,66 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolveRigidTransform(t *testing.T) {
+	rotation := AxisAngleRotation([3]float64{1, 2, 3}, 37)
+	translation := [3]float64{10, -5, 50}
+	tracker := [][3]float64{{0, 0, 0}, {30, 0, 0}, {0, 30, 0}, {0, 0, 30}, {12, -7, 4}}
+
+	var pairs []PointPair
+	for _, point := range tracker {
+		var platform [3]float64
+		for i := 0; i < 3; i++ {
+			platform[i] = translation[i]
+			for j := 0; j < 3; j++ {
+				platform[i] += rotation[i][j] * point[j]
+			}
+		}
+		pairs = append(pairs, PointPair{Tracker: point, Platform: platform})
+	}
+
+	solvedRotation, solvedTranslation, err := SolveRigidTransform(pairs)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			assert.InDelta(t, rotation[i][j], solvedRotation[i][j], 1e-9)
+		}
+		assert.InDelta(t, translation[i], solvedTranslation[i], 1e-9)
+	}
+
+	_, _, err = SolveRigidTransform(pairs[:2])
+	assert.Error(t, err)
+
+	collinear := []PointPair{
+		{Tracker: [3]float64{0, 0, 0}}, {Tracker: [3]float64{1, 0, 0}}, {Tracker: [3]float64{2, 0, 0}},
+	}
+	_, _, err = SolveRigidTransform(collinear)
+	assert.Error(t, err)
+}
+
+func TestCalibrateFrame(t *testing.T) {
+	client := newTestClient(t)
+	pairs := []PointPair{
+		{Tracker: [3]float64{0, 0, 0}, Platform: [3]float64{0, 0, 50}},
+		{Tracker: [3]float64{10, 0, 0}, Platform: [3]float64{10, 0, 50}},
+		{Tracker: [3]float64{0, 10, 0}, Platform: [3]float64{0, 0, 60}},
+		{Tracker: [3]float64{0, 0, 10}, Platform: [3]float64{0, -10, 50.5}},
+	}
+
+	calibration, err := client.CalibrateFrame(pairs, "", 0.01)
+	assert.Error(t, err)
+	assert.False(t, calibration.Applied)
+	assert.Equal(t, "default", client.Frame().Source)
+
+	calibration, err = client.CalibrateFrame(pairs, "", 1)
+	assert.NoError(t, err)
+	assert.True(t, calibration.Applied)
+	assert.Len(t, calibration.Residuals, 4)
+	assert.Greater(t, calibration.RMSResidual, 0.0)
+	assert.Equal(t, "calibration", client.Frame().Source)
+	assert.InDelta(t, 1, client.Frame().Rotation[2][1], 0.05)
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package capture records the serial frames exchanged with the bow
+// controller. A capture is a text file starting with the header line
+//
+//	# digitalbow-capture 1 <start time, RFC 3339>
+//
+// followed by one line per frame:
+//
+//	<offset> <direction> <frame>
+//
+// offset is the number of microseconds since the start of the capture,
+// measured on the monotonic clock so that it never goes backwards when the
+// wall clock is adjusted. direction is "tx" for a frame written to the
+// controller and "rx" for a frame read from it. frame is the whole frame,
+// header and checksum included, in lower case hex. Other lines starting with
+// "#" are comments.
+package capture
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the capture format version written in the header.
+const Version = 1
+
+const magic = "# digitalbow-capture"
+
+// Direction tells whether a frame was written or read.
+type Direction string
+
+// Frame directions.
+const (
+	Tx Direction = "tx"
+	Rx Direction = "rx"
+)
+
+// Record is one captured frame.
+type Record struct {
+	// Offset is the time since the start of the capture.
+	Offset    time.Duration
+	Direction Direction
+	Data      []byte
+}
+
+func (r Record) String() string {
+	return fmt.Sprintf("%d %s %x", r.Offset.Microseconds(), r.Direction, r.Data)
+}
+
+// Writer writes a capture. It is safe for concurrent use.
+type Writer struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	start  time.Time
+}
+
+// Create creates the capture file at path, truncating an existing one.
+func Create(path string) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	w.closer = file
+	return w, nil
+}
+
+// NewWriter writes the capture header to w. The capture starts now.
+func NewWriter(w io.Writer) (*Writer, error) {
+	writer := &Writer{w: bufio.NewWriter(w), start: time.Now()}
+	if _, err := fmt.Fprintf(writer.w, "%s %d %s\n", magic, Version, writer.start.Format(time.RFC3339Nano)); err != nil {
+		return nil, err
+	}
+	return writer, writer.w.Flush()
+}
+
+// Record appends a frame. Every record is flushed so that a capture survives
+// a crash of the mapper.
+func (w *Writer) Record(direction Direction, data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	record := Record{Offset: time.Since(w.start), Direction: direction, Data: data}
+	if _, err := fmt.Fprintln(w.w, record); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// Close flushes the capture and closes the file opened by Create.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.w.Flush()
+	if w.closer != nil {
+		if closeErr := w.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Reader reads a capture.
+type Reader struct {
+	// Start is the wall clock time the capture started.
+	Start   time.Time
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader reads the capture header from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{scanner: bufio.NewScanner(r)}
+	if !reader.scanner.Scan() {
+		if err := reader.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("empty capture")
+	}
+	reader.line = 1
+	header := reader.scanner.Text()
+	if !strings.HasPrefix(header, magic+" ") {
+		return nil, fmt.Errorf("not a capture: %q", header)
+	}
+	fields := strings.Fields(strings.TrimPrefix(header, magic))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid capture header %q", header)
+	}
+	if fields[0] != strconv.Itoa(Version) {
+		return nil, fmt.Errorf("unsupported capture version %s", fields[0])
+	}
+	start, err := time.Parse(time.RFC3339Nano, fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid capture start: %v", err)
+	}
+	reader.Start = start
+	return reader, nil
+}
+
+// Read returns the next record, or io.EOF at the end of the capture.
+func (r *Reader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		record, err := parseRecord(line)
+		if err != nil {
+			return Record{}, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+func parseRecord(line string) (Record, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return Record{}, fmt.Errorf("want offset, direction and frame, got %q", line)
+	}
+	offset, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || offset < 0 {
+		return Record{}, fmt.Errorf("invalid offset %q", fields[0])
+	}
+	direction := Direction(fields[1])
+	if direction != Tx && direction != Rx {
+		return Record{}, fmt.Errorf("invalid direction %q", fields[1])
+	}
+	data, err := hex.DecodeString(fields[2])
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid frame: %v", err)
+	}
+	return Record{Offset: time.Duration(offset) * time.Microsecond, Direction: direction, Data: data}, nil
+}
+
+// ReadFile reads every record of the capture at path.
+func ReadFile(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var records []Record
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,105 @@
+package capture
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+)
+
+func encode(t *testing.T, frame protocol.Frame) []byte {
+	data, err := frame.Encode()
+	require.NoError(t, err)
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.cap")
+	w, err := Create(path)
+	require.NoError(t, err)
+	home := encode(t, protocol.Frame{Address: protocol.BroadcastAddress, Command: protocol.CommandHome})
+	ack := encode(t, protocol.Frame{Address: 1, Command: protocol.CommandAck, Payload: protocol.Ack{Command: protocol.CommandHome}.Payload()})
+	require.NoError(t, w.Record(Tx, home))
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, w.Record(Rx, ack))
+	require.NoError(t, w.Close())
+
+	records, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, Tx, records[0].Direction)
+	assert.Equal(t, home, records[0].Data)
+	assert.Equal(t, Rx, records[1].Direction)
+	assert.Equal(t, ack, records[1].Data)
+	assert.GreaterOrEqual(t, int64(records[1].Offset-records[0].Offset), int64(2*time.Millisecond))
+}
+
+func TestReader(t *testing.T) {
+	capture := "# digitalbow-capture 1 2020-06-01T10:00:00Z\n" +
+		"# a comment\n" +
+		"\n" +
+		"1500 tx 55aa01fff1f1\n"
+	r, err := NewReader(strings.NewReader(capture))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC), r.Start)
+	record, err := r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, Record{Offset: 1500 * time.Microsecond, Direction: Tx, Data: []byte{0x55, 0xaa, 0x01, 0xff, 0xf1, 0xf1}}, record)
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+
+	for _, bad := range []string{
+		"",
+		"not a capture\n",
+		"# digitalbow-capture 2 2020-06-01T10:00:00Z\n",
+		"# digitalbow-capture 1 yesterday\n",
+	} {
+		_, err = NewReader(strings.NewReader(bad))
+		assert.Error(t, err, bad)
+	}
+	for _, bad := range []string{"-1 tx 55aa", "10 up 55aa", "10 tx 55a", "10 tx"} {
+		r, err = NewReader(strings.NewReader("# digitalbow-capture 1 2020-06-01T10:00:00Z\n" + bad + "\n"))
+		require.NoError(t, err)
+		_, err = r.Read()
+		assert.Error(t, err, bad)
+		assert.Contains(t, err.Error(), "line 2")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	set := func(first int16) Record {
+		return Record{Direction: Tx, Data: encode(t, protocol.SetPositionsFrame(protocol.BroadcastAddress, protocol.Positions{first}))}
+	}
+	report := Record{Direction: Rx, Data: encode(t, protocol.Frame{Address: 1, Command: protocol.CommandPositions, Payload: protocol.Positions{}.Payload()})}
+	ack := Record{Direction: Rx, Data: encode(t, protocol.Frame{Address: 1, Command: protocol.CommandAck, Payload: protocol.Ack{Command: protocol.CommandSetPositions}.Payload()})}
+
+	previous := []Record{set(100), report, ack, set(200), ack}
+	current := []Record{set(101), ack, set(200), report, report, ack, set(300)}
+
+	differences := Diff(previous, current, DiffOptions{Direction: Tx})
+	require.Len(t, differences, 2)
+	assert.Equal(t, 0, differences[0].Index)
+	assert.Equal(t, 2, differences[1].Index)
+	assert.Nil(t, differences[1].Previous)
+	assert.Contains(t, differences[1].String(), "missing ->")
+
+	differences = Diff(previous, current, DiffOptions{Direction: Tx, Tolerance: 1})
+	require.Len(t, differences, 1)
+	assert.Equal(t, 2, differences[0].Index)
+
+	assert.NotEmpty(t, Diff(previous, current, DiffOptions{Direction: Rx}))
+	assert.Empty(t, Diff(previous, current, DiffOptions{Direction: Rx, Ignore: []protocol.Command{protocol.CommandPositions}}))
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, w.Record(Tx, set(1).Data))
+	assert.Contains(t, buf.String(), " tx 55aa")
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capture
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+)
+
+// DiffOptions selects the frames Diff compares.
+type DiffOptions struct {
+	Direction Direction
+	// Tolerance is the largest difference in counts between two cylinder
+	// positions still considered equal.
+	Tolerance int
+	// Ignore lists the commands left out, e.g. the position reports whose
+	// number depends on the timing of a run.
+	Ignore []protocol.Command
+}
+
+// Difference is a frame that differs between two captures. Previous or
+// Current is nil when one capture has fewer frames.
+type Difference struct {
+	// Index is the position of the frame among the compared frames.
+	Index    int
+	Previous *Record
+	Current  *Record
+}
+
+func (d Difference) String() string {
+	side := func(r *Record) string {
+		if r == nil {
+			return "missing"
+		}
+		return fmt.Sprintf("%x", r.Data)
+	}
+	return fmt.Sprintf("frame %d: %s -> %s", d.Index, side(d.Previous), side(d.Current))
+}
+
+// Diff compares the frames of one direction of two captures in order,
+// ignoring their timing.
+func Diff(previous, current []Record, options DiffOptions) []Difference {
+	previous, current = options.filter(previous), options.filter(current)
+	var differences []Difference
+	for i := 0; i < len(previous) || i < len(current); i++ {
+		var p, c *Record
+		if i < len(previous) {
+			p = &previous[i]
+		}
+		if i < len(current) {
+			c = &current[i]
+		}
+		if p == nil || c == nil || !options.equal(p.Data, c.Data) {
+			differences = append(differences, Difference{Index: i, Previous: p, Current: c})
+		}
+	}
+	return differences
+}
+
+func (o DiffOptions) filter(records []Record) []Record {
+	var filtered []Record
+	for _, record := range records {
+		if record.Direction != o.Direction || o.ignored(record.Data) {
+			continue
+		}
+		filtered = append(filtered, record)
+	}
+	return filtered
+}
+
+func (o DiffOptions) ignored(data []byte) bool {
+	frame, _, err := protocol.Decode(data)
+	if err != nil {
+		return false
+	}
+	for _, command := range o.Ignore {
+		if frame.Command == command {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares two frames, allowing Tolerance on cylinder positions.
+func (o DiffOptions) equal(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	if o.Tolerance == 0 {
+		return false
+	}
+	fa, pa, ok := positions(a)
+	if !ok {
+		return false
+	}
+	fb, pb, ok := positions(b)
+	if !ok || fa.Address != fb.Address || fa.Command != fb.Command {
+		return false
+	}
+	for i := range pa {
+		d := int(pa[i]) - int(pb[i])
+		if d > o.Tolerance || d < -o.Tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// positions decodes a set-positions or positions frame.
+func positions(data []byte) (protocol.Frame, protocol.Positions, bool) {
+	frame, _, err := protocol.Decode(data)
+	if err != nil || (frame.Command != protocol.CommandSetPositions && frame.Command != protocol.CommandPositions) {
+		return frame, protocol.Positions{}, false
+	}
+	p, err := protocol.ParsePositions(frame.Payload)
+	return frame, p, err == nil
+}
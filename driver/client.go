@@ -16,14 +16,6 @@ limitations under the License.
 
 package driver
 
-//#cgo CFLAGS: -I./number
-//#cgo LDFLAGS: -L${SRCDIR}/number -lSixDof -lm
-//
-//#include <stdio.h>
-//#include <stdlib.h>
-//#include <math.h>
-//#include "s_6dof.h"
-import "C"
 import (
 	"encoding/json"
 	"errors"
@@ -36,15 +28,27 @@ import (
 	"k8s.io/klog/v2"
 
 	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
+	"github.com/smilelinkd/digitalbow-mapper/driver/capture"
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+	"github.com/smilelinkd/digitalbow-mapper/driver/rotation"
 	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
 )
 
+// Largest pose error accepted by CheckFrame.
 const (
-	IP_A_x float64 = 0
-	IP_A_y float64 = 0
-	IP_A_z float64 = 50
+	checkAngleTolerance       = 0.01
+	checkTranslationTolerance = 1e-5
 )
 
+// ErrNoGeometry is returned by the forward kinematics when no platform
+// geometry is configured.
+var ErrNoGeometry = errors.New("no platform geometry configured")
+
+// DefaultEulerOrder is the Euler convention of the bow controller: yaw about
+// Z, then pitch about the new Y, then roll about the new X.
+const DefaultEulerOrder = "ZYX"
+
 // BowRTUConfig is the configurations of modbus RTU.
 type BowRTUConfig struct {
 	SerialName   string
@@ -53,7 +57,52 @@ type BowRTUConfig struct {
 	StopBits     int
 	Parity       string
 	RS485Enabled bool
-	Timeout      time.Duration
+	// RS485 is the RTS toggling, only allowed when RS485Enabled.
+	RS485 RS485Config
+	// Timeout is the read timeout between two bytes, zero to block until data.
+	Timeout time.Duration
+	// Solver selects the inverse kinematics implementation, see NewSolver.
+	Solver string
+	// Geometry is the measured joint layout of the platform. The go and
+	// compare solvers and the forward kinematics need it; libSixDof has its
+	// own compiled in.
+	Geometry kinematics.Geometry
+	Limits   kinematics.Limits
+	// PlaybackRate is the update rate in Hz the bow controller expects. Tracks
+	// are played at their own frequency when zero.
+	PlaybackRate int
+	MotionLimits MotionLimits
+	// Frame is the U->A transform of the installation.
+	Frame FrameConfig
+	// EulerOrder is the rotation sequence the bow expects, see
+	// rotation.ParseSequence. DefaultEulerOrder is used when empty.
+	EulerOrder string
+	// Composition is the default composition mode of the tracks, see
+	// ComposeFrame.
+	Composition string
+	// Reconnect is the backoff reopening a failed serial port.
+	Reconnect Backoff
+	// Capture is the file recording the serial frames, see package capture.
+	// Nothing is recorded when empty.
+	Capture string
+	// StopRamp is how long a stopped execution takes to bring the platform
+	// back to zero, DefaultStopRamp when zero.
+	StopRamp time.Duration
+	// SeekTransition is how long a seek takes to move the platform to the
+	// frame sought, DefaultSeekTransition when zero.
+	SeekTransition time.Duration
+	// Calibration converts the cylinder lengths into encoder counts,
+	// DefaultCalibration when zero.
+	Calibration Calibration
+	// Homing is the homing sequence run on start and on demand.
+	Homing HomingConfig
+	// ProtocolExtensions lets the driver send the commands beyond the
+	// controller protocol, see protocol.Command.Extension. Only the simulator
+	// answers them so far; homing needs them.
+	ProtocolExtensions bool
+	// CalibrationFile stores the calibrated cylinder offsets, which override
+	// the configured ones once stored. Nothing is stored when empty.
+	CalibrationFile string
 }
 
 type TrackData struct {
@@ -64,6 +113,8 @@ type TrackData struct {
 	IPList     [][3]float64    `json:"IP_list"`
 	LCList     [][3]float64    `json:"LC_list"`
 	RCList     [][3]float64    `json:"RC_list"`
+	// Composition overrides the device composition mode for this track.
+	Composition string `json:"composition,omitempty"`
 }
 
 type Client interface {
@@ -75,10 +126,11 @@ type Client interface {
 
 type BowClient struct {
 	Config BowRTUConfig
+	Solver Solver
 }
 
 func (bowClient BowClient) Init() {
-	C.SixDOFInit()
+	bowClient.Solver.Init()
 	klog.V(2).Info("init success...")
 }
 
@@ -100,17 +152,54 @@ func (bowClient BowClient) GetStatus() interface{} {
 
 func (bowClient BowClient) Execute(movements []float32, clylen []float32) {
 	klog.V(2).Infof("execute input %v", movements)
-	C.SoluteCylinderLength((*C.float)(&movements[0]), (*C.float)(&clylen[0]))
+	bowClient.Solver.SoluteCylinderLength(movements, clylen)
 }
 
+// DataPublisher publishes the value of a device data property.
+type DataPublisher func(name string, valueType string, value string) error
+
 // DigitalbowClient is the structure for modbus client.
 type DigitalbowClient struct {
-	Client       BowClient
-	Status       common.DeviceStatus
+	Client BowClient
+	Status common.DeviceStatus
+	// Movements are the downloaded segments, guarded by mu; see Movement.
 	Movements    map[string]TrackData
 	mu           sync.Mutex
 	Transform_AU *mat.Dense
 	Rotation_AU  *mat.Dense
+	platform     *kinematics.Platform
+	frame        FrameConfig
+	// lengthScale converts track translations into meters.
+	lengthScale float64
+	publisher   DataPublisher
+	euler       rotation.Sequence
+	session     *Session
+	// twinPublisher publishes the controller feedback twin properties.
+	twinPublisher      DataPublisher
+	feedback           Feedback
+	positionsPublished time.Time
+	// capture records the serial frames when configured.
+	capture *capture.Writer
+	// execution is the running execution, nil when none is.
+	execution *execution
+	lastStop  *StopRecord
+	// jobs is the queue of the executions to run.
+	jobs jobQueue
+	// statusHistory is the last status transitions, oldest first.
+	statusHistory []StatusTransition
+	// heldStatus is the Fault or Locked status the device had when the
+	// connection was lost, restored once it is back.
+	heldStatus common.DeviceStatus
+	// calibration converts the cylinder lengths into encoder counts.
+	calibration Calibration
+	// calibrating is set while new offsets are stored, and holds back
+	// executions and other calibrations meanwhile.
+	calibrating bool
+	// homingError is why the last homing failed, nil once homed; the device
+	// stays in Fault until homed.
+	homingError error
+	lastHoming  *HomingReport
+	homed       bool
 }
 
 /*
@@ -120,59 +209,66 @@ type DigitalbowClient struct {
  */
 var clients map[string]*DigitalbowClient
 
-func newRTUClient(config BowRTUConfig) *DigitalbowClient {
+func newRTUClient(config BowRTUConfig) (*DigitalbowClient, error) {
 	if clients == nil {
 		clients = make(map[string]*DigitalbowClient)
 	}
 
 	if client, ok := clients[config.SerialName]; ok {
-		return client
+		return client, nil
 	}
 
-	//# 平移向量:
-	UO_A_x := IP_A_x
-	UO_A_y := IP_A_y
-	UO_A_z := IP_A_z
-
-	//# U->A的旋转矩阵，变换矩阵:
-	//# 3X3 旋转矩阵
-	Rotation_AU := mat.NewDense(3, 3, nil)
-	Rotation_AU.SetRow(0, []float64{
-		1, 0, 0,
-	})
-	Rotation_AU.SetRow(1, []float64{
-		0, math.Cos(math.Pi * 90 / 180), -math.Sin(math.Pi * 90 / 180),
-	})
-	Rotation_AU.SetRow(2, []float64{
-		0, math.Sin(math.Pi * 90 / 180), math.Cos(math.Pi * 90 / 180),
-	})
-
-	Transform_AU := mat.NewDense(4, 4, nil)
-	Transform_AU.SetRow(0, []float64{
-		1, 0, 0, UO_A_x,
-	})
-	Transform_AU.SetRow(1, []float64{
-		0, math.Cos(math.Pi * 90 / 180), -math.Sin(math.Pi * 90 / 180), UO_A_y,
-	})
-	Transform_AU.SetRow(2, []float64{
-		0, math.Sin(math.Pi * 90 / 180), math.Cos(math.Pi * 90 / 180), UO_A_z,
-	})
-	Transform_AU.SetRow(3, []float64{
-		0, 0, 0, 1,
-	})
+	solver, err := NewSolver(config.Solver, config.Geometry)
+	if err != nil {
+		return nil, err
+	}
+	var platform *kinematics.Platform
+	if config.Geometry != (kinematics.Geometry{}) {
+		if platform, err = kinematics.NewPlatform(config.Geometry); err != nil {
+			return nil, err
+		}
+	}
+	if config.EulerOrder == "" {
+		config.EulerOrder = DefaultEulerOrder
+	}
+	euler, err := rotation.ParseSequence(config.EulerOrder)
+	if err != nil {
+		return nil, err
+	}
+	if err = ValidateComposition(config.Composition); err != nil {
+		return nil, err
+	}
+	calibration := config.Calibration
+	if calibration == (Calibration{}) {
+		calibration = DefaultCalibration()
+	}
+	if config.CalibrationFile != "" {
+		if err = loadOffsets(config.CalibrationFile, &calibration); err != nil {
+			return nil, err
+		}
+	}
+	if err = calibration.Validate(); err != nil {
+		return nil, err
+	}
 
 	client := DigitalbowClient{
 		Status: common.StatusReady,
 		Client: BowClient{
 			Config: config,
+			Solver: solver,
 		},
-		Movements:    make(map[string]TrackData, 0),
-		Transform_AU: Transform_AU,
-		Rotation_AU:  Rotation_AU,
+		Movements:   make(map[string]TrackData, 0),
+		platform:    platform,
+		euler:       euler,
+		calibration: calibration,
+	}
+	//# U->A的旋转矩阵，变换矩阵
+	if err = client.SetFrame(config.Frame); err != nil {
+		return nil, err
 	}
 
 	clients[config.SerialName] = &client
-	return &client
+	return &client, nil
 }
 
 // NewClient allocate and return a modbus client.
@@ -180,7 +276,7 @@ func newRTUClient(config BowRTUConfig) *DigitalbowClient {
 func NewClient(config interface{}) (*DigitalbowClient, error) {
 	switch c := config.(type) {
 	case BowRTUConfig:
-		return newRTUClient(c), nil
+		return newRTUClient(c)
 	default:
 		return &DigitalbowClient{}, errors.New("Wrong type")
 	}
@@ -194,20 +290,36 @@ func (c *DigitalbowClient) GetStatus() common.DeviceStatus {
 	return c.Status
 }
 
-// SetStatus set device status.
-// Now we could only get the connection status.
-func (c *DigitalbowClient) SetStatus(status common.DeviceStatus) {
+// SetDataPublisher sets where PublishData sends device data.
+func (c *DigitalbowClient) SetDataPublisher(publisher DataPublisher) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.Status = status
+	c.publisher = publisher
 }
 
-func (c *DigitalbowClient) DownloadResult(path, segment string) (err error) {
-	var movement TrackData
+// PublishData publishes value as the JSON string of the data property name.
+func (c *DigitalbowClient) PublishData(name string, value interface{}) error {
+	c.mu.Lock()
+	publisher := c.publisher
+	c.mu.Unlock()
+	if publisher == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return publisher(name, "string", string(data))
+}
+
+// DownloadResult downloads and validates the track of a segment. An invalid
+// track is not stored; its validation is returned with ErrInvalidTrack. A
+// non empty composition overrides the one of the track file.
+func (c *DigitalbowClient) DownloadResult(path, segment string, orthonormalize bool, composition string) (validation TrackValidation, err error) {
 	obsConfig := GetObs()
 	obsClient, err := obs.New(obsConfig["AK"], obsConfig["SK"], obsConfig["URI"])
 	if err != nil {
-		return err
+		return validation, err
 	}
 	input := &obs.GetObjectInput{}
 	input.Bucket = obsConfig["NAME"]
@@ -240,14 +352,43 @@ func (c *DigitalbowClient) DownloadResult(path, segment string) (err error) {
 		fmt.Println(obsError.Error())
 		return
 	}
+	defer obsClient.Close()
+	return c.LoadTrack(segment, content, orthonormalize, composition)
+}
+
+// LoadTrack decodes the JSON track of segment, validates it under its
+// composition mode and stores it. A composition overrides the one of the
+// track when not empty.
+func (c *DigitalbowClient) LoadTrack(segment string, content []byte, orthonormalize bool, composition string) (validation TrackValidation, err error) {
+	var movement TrackData
 	err = json.Unmarshal(content, &movement)
 	if err != nil {
 		fmt.Println("unmarshall error", err.Error())
 		return
 	}
-	defer obsClient.Close()
+	if composition != "" {
+		movement.Composition = composition
+	}
+	validation = ValidateTrack(&movement, c.composition(movement), orthonormalize)
+	validation.Segment = segment
+	if !validation.Valid() {
+		return validation, ErrInvalidTrack
+	}
+	if len(validation.Repaired) != 0 {
+		klog.Warningf("Segment %s re-orthonormalised frames %v", segment, validation.Repaired)
+	}
+	c.mu.Lock()
 	c.Movements[segment] = movement
-	return nil
+	c.mu.Unlock()
+	return validation, nil
+}
+
+// Movement returns the downloaded track of segment.
+func (c *DigitalbowClient) Movement(segment string) (TrackData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	movement, ok := c.Movements[segment]
+	return movement, ok
 }
 
 // Set set register.
@@ -265,47 +406,32 @@ type EulerAngle struct {
 	roll, pitch, yaw float64
 }
 
-func matrixToEuler(m [][]float64) EulerAngle {
-	var euler EulerAngle
-
-	if m[2][0] == -1 {
-		euler.pitch = math.Pi / 2
-		euler.roll = math.Atan2(m[0][1], m[0][2])
-		euler.yaw = 0
-	} else if m[2][0] == 1 {
-		euler.pitch = -math.Pi / 2
-		euler.roll = math.Atan2(-m[0][1], -m[0][2])
-		euler.yaw = 0
-	} else {
-		// Roll (X-axis rotation)
-		euler.roll = math.Atan2(m[2][1], m[2][2])
-		// Pitch (Y-axis rotation)
-		euler.pitch = math.Asin(-m[2][0])
-		// Yaw (Z-axis rotation)
-		euler.yaw = math.Atan2(m[1][0], m[0][0])
+// matrixToEuler decomposes m with the converter's sequence and returns the
+// angles in degrees about X, Y and Z.
+func matrixToEuler(m [][]float64, converter *rotation.EulerConverter) EulerAngle {
+	var r rotation.Matrix
+	for i := 0; i < 3; i++ {
+		copy(r[i][:], m[i])
 	}
-
-	// Convert to degrees
-	euler.roll = euler.roll * 180.0 / math.Pi
-	euler.pitch = euler.pitch * 180.0 / math.Pi
-	euler.yaw = euler.yaw * 180.0 / math.Pi
-
-	return euler
+	angles := converter.Convert(r)
+	var byAxis [3]float64
+	for i, axis := range converter.Sequence.Axes() {
+		byAxis[axis] = angles[i]
+	}
+	return EulerAngle{roll: byAxis[0], pitch: byAxis[1], yaw: byAxis[2]}
 }
 
+// GetBowDataformat returns the bow pose of one track frame composed with
+// matrixInit in the device composition mode. Use SegmentPoses for a whole
+// track, whose angles are kept continuous across frames.
 func (c *DigitalbowClient) GetBowDataformat(trackData [4][4]float64, matrixInit [4][4]float64) []float64 {
+	loopMatrix := ComposeFrame(c.composition(TrackData{}), trackData, matrixInit)
+	return c.bowDataformat(loopMatrix, rotation.NewEulerConverter(c.euler))
+}
+
+func (c *DigitalbowClient) bowDataformat(loopMatrix *mat.Dense, converter *rotation.EulerConverter) []float64 {
 	result := make([]float64, 6)
 
-	trackMatrix := mat.NewDense(4, 4, nil)
-	for i := 0; i < 4; i++ {
-		trackMatrix.SetRow(i, trackData[i][:])
-	}
-	trackInitMatrix := mat.NewDense(4, 4, nil)
-	for i := 0; i < 4; i++ {
-		trackInitMatrix.SetRow(i, matrixInit[i][:])
-	}
-	var loopMatrix mat.Dense
-	loopMatrix.Add(trackMatrix, trackInitMatrix)
 	//#--------- 分别计算A坐标系下的欧拉角，和 平移向量
 	//# 旋转矩阵 3X3
 	rotateMatrix := [][]float64{
@@ -314,39 +440,84 @@ func (c *DigitalbowClient) GetBowDataformat(trackData [4][4]float64, matrixInit
 		loopMatrix.RawRowView(2)[0:3],
 	}
 	//# 旋转矩阵 转换 欧拉角
-	eulerAngle := matrixToEuler(rotateMatrix)
+	eulerAngle := matrixToEuler(rotateMatrix, converter)
+	c.mu.Lock()
+	rotationAU, transformAU, lengthScale := c.Rotation_AU, c.Transform_AU, c.lengthScale
+	c.mu.Unlock()
 	//# A坐标系下：计算欧拉角，这一步不能提前，必须在欧拉角算出来后，把U坐标系下的欧拉角换到A坐标系下
 	var eulerA mat.Dense
 	eulerAngleMatrix := mat.NewDense(3, 1, []float64{
 		eulerAngle.roll, eulerAngle.pitch, eulerAngle.yaw,
 	})
-	eulerA.Mul(c.Rotation_AU, eulerAngleMatrix)
-	//# A坐标系下：计算平移向量，位移单位要求为m。 之前是mm，所以要除1000
+	eulerA.Mul(rotationAU, eulerAngleMatrix)
+	//# A坐标系下：计算平移向量，位移单位要求为m，按配置的长度单位换算
 	var vectorA mat.Dense
-	vectorA.Mul(c.Transform_AU, &loopMatrix)
+	vectorA.Mul(transformAU, loopMatrix)
 
 	result[0] = eulerA.At(0, 0)
 	result[1] = eulerA.At(1, 0)
 	result[2] = eulerA.At(2, 0)
-	result[3] = vectorA.At(0, 3) / 1000
-	result[4] = vectorA.At(1, 3) / 1000
-	result[4] = vectorA.At(2, 3) / 1000
+	result[3] = vectorA.At(0, 3) * lengthScale
+	result[4] = vectorA.At(1, 3) * lengthScale
+	result[5] = vectorA.At(2, 3) * lengthScale
 	return result
 }
 
-func (c *DigitalbowClient) AssembleSerialData(moves []float32) []byte {
-	b := []byte{0x55, 0xAA, 0x13, 0xFF, 0xF3}
-	sum := 0x13 + 0xFF + 0xF3
-	for i, item := range moves {
-		id := byte(i + 1)
-		number1 := int32((item - 0.1569) * 40000)
-		number1_1 := byte(number1)
-		number1_2 := byte(number1 >> 8)
-		b = append(b, id, number1_1, number1_2)
-		sum += int(id) + int(number1_2) + int(number1_1)
-	}
-	b = append(b, byte(sum))
-	return b
+// PlaybackTrack returns the downloaded segment resampled to rate Hz, or to the
+// configured playback rate when rate is zero.
+func (c *DigitalbowClient) PlaybackTrack(segment string, rate int) (TrackData, error) {
+	trackData, ok := c.Movement(segment)
+	if !ok {
+		return trackData, fmt.Errorf("segment %s does not exist", segment)
+	}
+	if rate == 0 {
+		rate = c.Client.Config.PlaybackRate
+	}
+	if rate == 0 {
+		return trackData, nil
+	}
+	return ResampleTrack(trackData, rate)
+}
+
+// SegmentPoses returns the bow pose of every frame of the track, composed in
+// the track's composition mode and relative to the first frame.
+func (c *DigitalbowClient) SegmentPoses(trackData TrackData) [][]float32 {
+	poses := make([][]float32, 0, len(trackData.MatrixList))
+	var aInit []float64
+	converter := rotation.NewEulerConverter(c.euler)
+	mode, reference := c.composition(trackData), trackData.MatrixInit
+	if mode == CompositionRelative && len(trackData.MatrixList) != 0 {
+		reference = trackData.MatrixList[0]
+	}
+	for record, item := range trackData.MatrixList {
+		bowResult := c.bowDataformat(ComposeFrame(mode, item, reference), converter)
+		if record == 0 {
+			//# 记录第0帧的初始参数
+			aInit = bowResult
+		}
+		matrixA := mat.NewDense(1, 6, bowResult)
+		matrixInit := mat.NewDense(1, 6, aInit)
+		var sixdofA mat.Dense
+		sixdofA.Sub(matrixA, matrixInit)
+		input64 := sixdofA.RawRowView(0)
+		input32 := make([]float32, 6)
+		for i := 0; i < 6; i++ {
+			input32[i] = float32(input64[i])
+		}
+		poses = append(poses, input32)
+	}
+	return poses
+}
+
+// AssembleSerialData encodes the set-positions frame of the cylinder lengths
+// with the cylinder calibration. It fails when a length is beyond its soft
+// limits or can not be encoded.
+func (c *DigitalbowClient) AssembleSerialData(moves []float32) ([]byte, error) {
+	positions, err := c.Calibration().Positions(moves)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.SetPositionsFrame(protocol.BroadcastAddress, positions).Encode()
 }
 
 func (c *DigitalbowClient) RandomGetCylen(i int) []float32 {
@@ -359,11 +530,54 @@ func (c *DigitalbowClient) RandomGetCylen(i int) []float32 {
 	}
 }
 
-func (c *DigitalbowClient) ResetToZero() []byte {
+func (c *DigitalbowClient) ResetToZero() ([]byte, error) {
 	clylen := make([]float32, 6)
 	bowResult := []float32{0, 0, 0, 0, 0, 0}
 	c.Client.Execute(bowResult, clylen)
 	klog.V(2).Infof("execute output %v", clylen)
-	writeMessage := c.AssembleSerialData(clylen)
-	return writeMessage
+	return c.AssembleSerialData(clylen)
+}
+
+// PoseFromCylinders solves the forward kinematics: it returns the roll, pitch,
+// yaw, x, y, z pose that produces the six cylinder lengths. It needs the
+// platform geometry.
+func (c *DigitalbowClient) PoseFromCylinders(clylen []float32) ([]float64, error) {
+	if c.platform == nil {
+		return nil, ErrNoGeometry
+	}
+	if len(clylen) != kinematics.Legs {
+		return nil, fmt.Errorf("expect %d cylinder lengths, got %d", kinematics.Legs, len(clylen))
+	}
+	var cylinders [kinematics.Legs]float64
+	for i := range cylinders {
+		cylinders[i] = float64(clylen[i])
+	}
+	pose, err := c.platform.ForwardKinematics(cylinders, kinematics.Pose{})
+	if err != nil {
+		return nil, err
+	}
+	return pose[:], nil
+}
+
+// CheckFrame verifies that the cylinder lengths about to be sent reproduce the
+// commanded pose. Without a configured geometry, as with libSixDof alone,
+// there is nothing to check against.
+func (c *DigitalbowClient) CheckFrame(movements []float32, clylen []float32) error {
+	if c.platform == nil {
+		return nil
+	}
+	pose, err := c.PoseFromCylinders(clylen)
+	if err != nil {
+		return err
+	}
+	for i, value := range pose {
+		tolerance := checkTranslationTolerance
+		if i < 3 {
+			tolerance = checkAngleTolerance
+		}
+		if math.Abs(value-float64(movements[i])) > tolerance {
+			return fmt.Errorf("cylinder lengths %v reach pose %v instead of %v", clylen, pose, movements)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,121 @@
+package driver
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+)
+
+// testGeometry is a symmetric platform for the tests; it is no real bow.
+func testGeometry() kinematics.Geometry {
+	return kinematics.Geometry{
+		BaseJoints:     kinematics.HexagonJoints(0.25, 15),
+		PlatformJoints: kinematics.HexagonJoints(0.15, 45),
+		LegLength:      0.4,
+		HomeStroke:     0.1569,
+	}
+}
+
+func newTestClient(t *testing.T) *DigitalbowClient {
+	client, err := NewClient(BowRTUConfig{
+		SerialName: t.Name(),
+		Geometry:   testGeometry(),
+		Frame:      DefaultFrameConfig(),
+	})
+	assert.NoError(t, err)
+	forgetClient(t)
+	return client
+}
+
+// forgetClient drops the client of the test from the port registry once the
+// test is over, so that every run starts with a new client.
+func forgetClient(t *testing.T) {
+	t.Cleanup(func() { delete(clients, t.Name()) })
+}
+
+func TestCheckFrame(t *testing.T) {
+	client := newTestClient(t)
+
+	movements := []float32{-5, 5, -5, 0.01, -0.01, 0.01}
+	clylen := make([]float32, 6)
+	client.Client.Execute(movements, clylen)
+	assert.NoError(t, client.CheckFrame(movements, clylen))
+
+	pose, err := client.PoseFromCylinders(clylen)
+	assert.NoError(t, err)
+	for i := range movements {
+		assert.InDelta(t, movements[i], pose[i], 1e-4)
+	}
+
+	clylen[0] += 0.001
+	assert.Error(t, client.CheckFrame(movements, clylen))
+}
+
+func TestGeometryRequired(t *testing.T) {
+	// The Go solver has no default geometry.
+	_, err := NewClient(BowRTUConfig{SerialName: t.Name(), Solver: SolverGo, Frame: DefaultFrameConfig()})
+	assert.Error(t, err)
+
+	// Without a geometry there is no forward kinematics to check frames with.
+	client := &DigitalbowClient{}
+	_, err = client.PoseFromCylinders(make([]float32, kinematics.Legs))
+	assert.Equal(t, ErrNoGeometry, err)
+	assert.NoError(t, client.CheckFrame(make([]float32, kinematics.Legs), make([]float32, kinematics.Legs)))
+}
+
+func TestCheckLimits(t *testing.T) {
+	client := newTestClient(t)
+	client.Client.Config.Limits.MaxTilt = 5
+
+	poses := [][]float32{
+		{0, 0, 0, 0, 0, 0},
+		{6, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 1},
+	}
+	report := client.CheckLimits("test", poses)
+	assert.Equal(t, 3, report.Frames)
+	assert.Equal(t, []int{1, 2}, report.FrameIndexes())
+}
+
+func TestSegmentPosesUnwrapEuler(t *testing.T) {
+	client := newTestClient(t)
+
+	// Yaw crosses 180 degrees; the poses must not jump by a full turn.
+	var track TrackData
+	for yaw := 170.0; yaw <= 190; yaw++ {
+		track.MatrixList = append(track.MatrixList, transformZ(yaw, 0))
+	}
+	poses := client.SegmentPoses(track)
+	for i := 1; i < len(poses); i++ {
+		for k := 0; k < 3; k++ {
+			assert.InDelta(t, poses[i-1][k], poses[i][k], 1.01, "frame %d angle %d", i, k)
+		}
+	}
+	last := poses[len(poses)-1]
+	assert.InDelta(t, 20, math.Abs(float64(last[0])+float64(last[1])+float64(last[2])), 1e-3)
+}
+
+func TestEulerOrderConfig(t *testing.T) {
+	_, err := NewClient(BowRTUConfig{
+		SerialName: t.Name(),
+		Geometry:   testGeometry(),
+		Frame:      DefaultFrameConfig(),
+		EulerOrder: "XYX",
+	})
+	assert.Error(t, err)
+}
+
+func TestAssembleSerialData(t *testing.T) {
+	client := newTestClient(t)
+
+	data, err := client.AssembleSerialData([]float32{0.1569, 0.1569, 0.1569, 0.1569, 0.1569, 0.1569})
+	assert.NoError(t, err)
+	assert.Equal(t, "55aa13fff30100000200000300000400000500000600001a", hex.EncodeToString(data))
+
+	_, err = client.AssembleSerialData([]float32{0.1569, 0.1569, 0.1569, 0.1569, 0.1569, 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Composition modes combining a track frame M with the reference transform I
+// before the pose is extracted.
+const (
+	// CompositionAdditive adds M and I element-wise, for tracks whose frames
+	// are deltas from Matrix_init. It is the default.
+	CompositionAdditive = "additive"
+	// CompositionPremultiply uses I·M, for frames relative to Matrix_init.
+	CompositionPremultiply = "premultiply"
+	// CompositionPostmultiply uses M·I, for frames expressed in the tracker
+	// frame of Matrix_init.
+	CompositionPostmultiply = "postmultiply"
+	// CompositionRelative uses F⁻¹·M where F is the first frame of the track,
+	// for absolute poses; Matrix_init is ignored.
+	CompositionRelative = "relative"
+)
+
+// ValidateComposition checks the composition mode is known. Empty selects the
+// default.
+func ValidateComposition(mode string) error {
+	switch mode {
+	case "", CompositionAdditive, CompositionPremultiply, CompositionPostmultiply, CompositionRelative:
+		return nil
+	default:
+		return fmt.Errorf("unknown composition mode %s", mode)
+	}
+}
+
+// ComposeFrame returns the transform of frame under mode. reference is
+// Matrix_init, or the first frame for CompositionRelative.
+func ComposeFrame(mode string, frame, reference [4][4]float64) *mat.Dense {
+	m, r := homogeneous(frame), homogeneous(reference)
+	var result mat.Dense
+	switch mode {
+	case CompositionPremultiply:
+		result.Mul(r, m)
+	case CompositionPostmultiply:
+		result.Mul(m, r)
+	case CompositionRelative:
+		result.Mul(homogeneous(rigidInverse(reference)), m)
+	default:
+		result.Add(m, r)
+	}
+	return &result
+}
+
+// composition returns the mode of the track, or the device default.
+func (c *DigitalbowClient) composition(trackData TrackData) string {
+	if trackData.Composition != "" {
+		return trackData.Composition
+	}
+	if c.Client.Config.Composition != "" {
+		return c.Client.Config.Composition
+	}
+	return CompositionAdditive
+}
+
+// rigidInverse inverts a rigid transform [R t] as [Rᵀ -Rᵀt].
+func rigidInverse(m [4][4]float64) [4][4]float64 {
+	var inverse [4][4]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			inverse[i][j] = m[j][i]
+			inverse[i][3] -= m[j][i] * m[j][3]
+		}
+	}
+	inverse[3][3] = 1
+	return inverse
+}
+
+func homogeneous(m [4][4]float64) *mat.Dense {
+	d := mat.NewDense(4, 4, nil)
+	for i := 0; i < 4; i++ {
+		d.SetRow(i, m[i][:])
+	}
+	return d
+}
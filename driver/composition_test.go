@@ -0,0 +1,163 @@
+package driver
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compositionCase is a track of frames built by frame(i), whose bow poses
+// relative to the first frame are expected(i).
+type compositionCase struct {
+	mode     string
+	init     [4][4]float64
+	frame    func(i float64) [4][4]float64
+	expected func(i float64) [6]float64
+}
+
+func subtract(a, b [4][4]float64) [4][4]float64 {
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			a[i][j] -= b[i][j]
+		}
+	}
+	return a
+}
+
+func newCompositionClient(t *testing.T, composition string) *DigitalbowClient {
+	client, err := NewClient(BowRTUConfig{
+		SerialName:  t.Name(),
+		Geometry:    testGeometry(),
+		Frame:       FrameConfig{Rotation: AxisAngleRotation([3]float64{0, 0, 1}, 0), LengthUnit: UnitMeter},
+		Composition: composition,
+	})
+	assert.NoError(t, err)
+	forgetClient(t)
+	return client
+}
+
+func TestComposition(t *testing.T) {
+	cos30, sin30 := math.Cos(math.Pi/6), math.Sin(math.Pi/6)
+	cases := []compositionCase{
+		{
+			// Deltas from an identity init.
+			mode: CompositionAdditive,
+			init: transformZ(0, 0),
+			frame: func(i float64) [4][4]float64 {
+				return subtract(transformZ(10*i, 0.01*i), transformZ(0, 0))
+			},
+			expected: func(i float64) [6]float64 { return [6]float64{0, 0, 10 * i, 0.01 * i, 0, 0} },
+		},
+		{
+			// Frames relative to the init transform.
+			mode:  CompositionPremultiply,
+			init:  transformZ(30, 0.1),
+			frame: func(i float64) [4][4]float64 { return transformZ(10*i, 0.01*i) },
+			expected: func(i float64) [6]float64 {
+				return [6]float64{0, 0, 10 * i, 0.01 * i * cos30, 0.01 * i * sin30, 0}
+			},
+		},
+		{
+			// Frames expressed before the init transform.
+			mode:  CompositionPostmultiply,
+			init:  transformZ(30, 0.1),
+			frame: func(i float64) [4][4]float64 { return transformZ(10*i, 0.01*i) },
+			expected: func(i float64) [6]float64 {
+				rad := 10 * i * math.Pi / 180
+				return [6]float64{0, 0, 10 * i, 0.01*i + 0.1*math.Cos(rad) - 0.1, 0.1 * math.Sin(rad), 0}
+			},
+		},
+		{
+			// Absolute frames; the init transform is ignored.
+			mode:  CompositionRelative,
+			frame: func(i float64) [4][4]float64 { return transformZ(30+10*i, 0.1+0.01*i) },
+			expected: func(i float64) [6]float64 {
+				return [6]float64{0, 0, 10 * i, 0.01 * i * cos30, -0.01 * i * sin30, 0}
+			},
+		},
+	}
+
+	client := newCompositionClient(t, "")
+	for _, c := range cases {
+		track := TrackData{MatrixInit: c.init, Composition: c.mode}
+		for i := 0; i < 5; i++ {
+			track.MatrixList = append(track.MatrixList, c.frame(float64(i)))
+		}
+		poses := client.SegmentPoses(track)
+		for i, pose := range poses {
+			expected := c.expected(float64(i))
+			for k := range pose {
+				assert.InDelta(t, expected[k], pose[k], 1e-5, "%s frame %d element %d", c.mode, i, k)
+			}
+		}
+	}
+}
+
+func TestCompositionDeviceDefault(t *testing.T) {
+	client := newCompositionClient(t, CompositionPremultiply)
+	track := TrackData{MatrixInit: transformZ(90, 0)}
+	track.MatrixList = [][4][4]float64{transformZ(0, 0), transformZ(0, 0.01)}
+	poses := client.SegmentPoses(track)
+	assert.InDelta(t, 0, poses[1][3], 1e-6)
+	assert.InDelta(t, 0.01, poses[1][4], 1e-6)
+
+	// The track mode overrides the device default.
+	track.Composition = CompositionPostmultiply
+	poses = client.SegmentPoses(track)
+	assert.InDelta(t, 0.01, poses[1][3], 1e-6)
+	assert.InDelta(t, 0, poses[1][4], 1e-6)
+}
+
+func TestValidateComposition(t *testing.T) {
+	assert.NoError(t, ValidateComposition(""))
+	assert.NoError(t, ValidateComposition(CompositionRelative))
+	assert.Error(t, ValidateComposition("multiply"))
+
+	_, err := NewClient(BowRTUConfig{SerialName: t.Name(), Geometry: testGeometry(),
+		Frame: DefaultFrameConfig(), Composition: "multiply"})
+	assert.Error(t, err)
+}
+
+func TestLoadAdditiveTrack(t *testing.T) {
+	client := newCompositionClient(t, "")
+	track := TrackData{Size: 5, Frequency: 30, MatrixInit: transformZ(0, 0)}
+	for i := 0; i < 5; i++ {
+		track.MatrixList = append(track.MatrixList, subtract(transformZ(10*float64(i), 0.01*float64(i)), transformZ(0, 0)))
+	}
+	// A drifted delta rotation is repaired so that the composed rotation is
+	// orthonormal.
+	track.MatrixList[2][0][0] += 0.005
+	content, err := json.Marshal(track)
+	require.NoError(t, err)
+
+	validation, err := client.LoadTrack("chewing", content, false, "")
+	assert.Equal(t, ErrInvalidTrack, err)
+	assert.Equal(t, 2, validation.Issues[0].Frame)
+
+	validation, err = client.LoadTrack("chewing", content, true, "")
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, validation.Repaired)
+	movement, ok := client.Movement("chewing")
+	require.True(t, ok)
+	poses := client.SegmentPoses(movement)
+	require.Len(t, poses, 5)
+	for i, pose := range poses {
+		assert.InDelta(t, 10*float64(i), pose[2], 0.5, "frame %d", i)
+		assert.InDelta(t, 0.01*float64(i), pose[3], 1e-5, "frame %d", i)
+	}
+
+	// Absolute frames are no additive deltas.
+	for i := range track.MatrixList {
+		track.MatrixList[i] = transformZ(10*float64(i), 0.01*float64(i))
+	}
+	content, err = json.Marshal(track)
+	require.NoError(t, err)
+	validation, err = client.LoadTrack("absolute", content, true, "")
+	assert.Equal(t, ErrInvalidTrack, err)
+	assert.Equal(t, "final row is [0 0 0 2], expect [0 0 0 1]", validation.Issues[0].Reason)
+	_, err = client.LoadTrack("absolute", content, false, CompositionRelative)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// DefaultSeekTransition is how long a seek takes to move the platform to the
+// frame sought when the device configures no seekTransition.
+const DefaultSeekTransition = time.Second
+
+// Errors steering an execution.
+var (
+	ErrNotControllable = errors.New("only a segment playback can be paused or sought")
+	ErrNotPaused       = errors.New("the execution is not paused")
+	ErrSeekRange       = errors.New("seek beyond the segment")
+)
+
+// ExecutionStatus is the device status and the progress of the running
+// execution.
+type ExecutionStatus struct {
+	Status  common.DeviceStatus `json:"status"`
+	Segment string              `json:"segment,omitempty"`
+	// Pass counts the passes of a looping playback from zero.
+	Pass int `json:"pass"`
+	// Frame is the segment frame sent last, Time its track time in seconds
+	// and Frames the number of frames of a pass.
+	Frame    int         `json:"frame"`
+	Frames   int         `json:"frames,omitempty"`
+	Time     float64     `json:"time"`
+	LastStop *StopRecord `json:"lastStop,omitempty"`
+}
+
+// ExecutionStatus returns the device status and the execution progress.
+func (c *DigitalbowClient) ExecutionStatus() ExecutionStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := ExecutionStatus{Status: c.Status}
+	if run := c.execution; run != nil {
+		status.Segment, status.Pass, status.Frame, status.Frames = run.segment, run.pass, run.segmentFrame(run.frame), run.frames
+		if run.frequency > 0 {
+			status.Time = float64(status.Frame) / float64(run.frequency)
+		}
+	}
+	if c.lastStop != nil {
+		record := *c.lastStop
+		status.LastStop = &record
+	}
+	return status
+}
+
+// Pause holds the running segment playback at its current pose.
+func (c *DigitalbowClient) Pause() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	run, err := c.controllable()
+	if err != nil {
+		return err
+	}
+	if c.Status == common.StatusPaused {
+		return nil
+	}
+	if c.Status != common.StatusExecucting {
+		return fmt.Errorf("device is %s", c.Status)
+	}
+	if err = c.setStatus(common.StatusPaused, fmt.Sprintf("pause segment %q", run.segment)); err != nil {
+		return err
+	}
+	run.control.Pause()
+	klog.V(1).Infof("Execution of segment %q paused at frame %d", run.segment, run.segmentFrame(run.frame))
+	return nil
+}
+
+// Resume continues a paused segment playback.
+func (c *DigitalbowClient) Resume() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	run, err := c.controllable()
+	if err != nil {
+		return err
+	}
+	if c.Status != common.StatusPaused {
+		return ErrNotPaused
+	}
+	if err = c.setStatus(common.StatusExecucting, fmt.Sprintf("resume segment %q", run.segment)); err != nil {
+		return err
+	}
+	run.control.Resume()
+	klog.V(1).Infof("Execution of segment %q resumed at frame %d", run.segment, run.segmentFrame(run.frame))
+	return nil
+}
+
+// Seek continues the running segment playback at segment frame, moving
+// smoothly to it. A pass playing the frame twice continues at the next time it
+// does. A paused playback stays paused at frame.
+func (c *DigitalbowClient) Seek(frame int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	run, err := c.controllable()
+	if err != nil {
+		return err
+	}
+	index, ok := run.passFrame(frame)
+	if !ok {
+		return fmt.Errorf("%w: frame %d is not played", ErrSeekRange, frame)
+	}
+	run.control.Seek(index)
+	klog.V(1).Infof("Execution of segment %q seeks frame %d", run.segment, frame)
+	return nil
+}
+
+// SeekTime is Seek to the frame at seconds of track time.
+func (c *DigitalbowClient) SeekTime(seconds float64) error {
+	c.mu.Lock()
+	run, err := c.controllable()
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return fmt.Errorf("%w: time %v", ErrSeekRange, seconds)
+	}
+	return c.Seek(int(math.Round(seconds * float64(run.frequency))))
+}
+
+// SeekFrameOrTime seeks the frame or the track time, whichever is set.
+func (c *DigitalbowClient) SeekFrameOrTime(frame *int, seconds *float64) error {
+	switch {
+	case frame != nil && seconds != nil:
+		return fmt.Errorf("%w: frame and time are exclusive", ErrSeekRange)
+	case frame != nil:
+		return c.Seek(*frame)
+	case seconds != nil:
+		return c.SeekTime(*seconds)
+	}
+	return fmt.Errorf("%w: no frame or time", ErrSeekRange)
+}
+
+// controllable returns the running segment playback. c.mu must be held.
+func (c *DigitalbowClient) controllable() (*execution, error) {
+	run := c.execution
+	if run == nil || run.stop != nil {
+		return nil, ErrNotRunning
+	}
+	if run.control == nil {
+		return nil, ErrNotControllable
+	}
+	return run, nil
+}
+
+// SeekTransition returns how long a seek takes to move to the frame sought.
+func (c *DigitalbowClient) SeekTransition() time.Duration {
+	if c.Client.Config.SeekTransition > 0 {
+		return c.Client.Config.SeekTransition
+	}
+	return DefaultSeekTransition
+}
@@ -0,0 +1,97 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+func TestExecutionControl(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+	assert.Equal(t, ErrNotRunning, client.Pause())
+
+	_, control, ok := client.BeginExecution("chewing", 100, 50)
+	require.True(t, ok)
+	client.ExecutionProgress(25)
+	status := client.ExecutionStatus()
+	assert.Equal(t, ExecutionStatus{Status: common.StatusExecucting, Segment: "chewing", Frame: 25, Frames: 100, Time: 0.5}, status)
+
+	assert.Equal(t, ErrNotPaused, client.Resume())
+	require.NoError(t, client.Pause())
+	assert.Equal(t, common.StatusPaused, client.GetStatus())
+	assert.True(t, control.Paused())
+
+	require.NoError(t, client.SeekTime(1.5))
+	_, seek := control.take()
+	assert.Equal(t, 75, seek)
+	frame := 99
+	require.NoError(t, client.SeekFrameOrTime(&frame, nil))
+	_, seek = control.take()
+	assert.Equal(t, 99, seek)
+	seconds := 1.0
+	assert.ErrorIs(t, client.SeekFrameOrTime(&frame, &seconds), ErrSeekRange)
+	assert.ErrorIs(t, client.SeekFrameOrTime(nil, nil), ErrSeekRange)
+	assert.ErrorIs(t, client.Seek(100), ErrSeekRange)
+	assert.ErrorIs(t, client.SeekTime(-1), ErrSeekRange)
+
+	require.NoError(t, client.Resume())
+	assert.Equal(t, common.StatusExecucting, client.GetStatus())
+	assert.False(t, control.Paused())
+
+	// A stopped execution cannot be steered any more.
+	_, err := client.Stop("done", "operator")
+	require.NoError(t, err)
+	assert.Equal(t, ErrNotRunning, client.Pause())
+	client.EndExecution()
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+
+	// Random executions have no playback to steer.
+	_, control, ok = client.BeginExecution("", 0, 0)
+	require.True(t, ok)
+	assert.Nil(t, control)
+	assert.Equal(t, ErrNotControllable, client.Pause())
+	client.EndExecution()
+}
+
+func TestExecutionControlWindow(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+
+	// Frames 19 down to 10 and back up to 19.
+	indexes, err := PlaybackOptions{Start: 10, End: 20, Reverse: true, PingPong: true}.Indexes(100)
+	require.NoError(t, err)
+	_, control, ok := client.beginExecution("chewing", len(indexes), 50, indexes)
+	require.True(t, ok)
+	defer client.EndExecution()
+
+	client.ExecutionProgress(0)
+	status := client.ExecutionStatus()
+	assert.Equal(t, 19, status.Frame)
+	assert.Equal(t, 19, status.Frames)
+	assert.InDelta(t, 0.38, status.Time, 1e-9)
+
+	// Seeks address segment frames.
+	require.NoError(t, client.Seek(15))
+	_, seek := control.take()
+	assert.Equal(t, 4, seek)
+	require.NoError(t, client.SeekTime(0.2))
+	_, seek = control.take()
+	assert.Equal(t, 9, seek)
+	assert.ErrorIs(t, client.Seek(5), ErrSeekRange)
+	assert.ErrorIs(t, client.Seek(20), ErrSeekRange)
+
+	// On the way back up the next time frame 15 is played is the later one.
+	client.ExecutionProgress(12)
+	assert.Equal(t, 13, client.ExecutionStatus().Frame)
+	require.NoError(t, client.Seek(15))
+	_, seek = control.take()
+	assert.Equal(t, 14, seek)
+
+	record, err := client.Stop("done", "operator")
+	require.NoError(t, err)
+	assert.Equal(t, 13, record.Frame)
+}
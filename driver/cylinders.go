@@ -0,0 +1,234 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+)
+
+// Errors of the cylinder calibration.
+var (
+	ErrSoftLimit          = errors.New("cylinder length beyond its soft limit")
+	ErrInvalidCalibration = errors.New("invalid cylinder calibration")
+	ErrCalibrationBusy    = errors.New("can not calibrate the cylinders while executing or calibrating")
+)
+
+// CylinderCalibration converts the length of one cylinder into its encoder
+// count: count = (length - Offset) * Scale, negated when Invert.
+type CylinderCalibration struct {
+	// Offset is the cylinder length in meters at count zero.
+	Offset float64 `json:"offset"`
+	// Scale is the encoder counts per meter.
+	Scale  float64 `json:"scale"`
+	Invert bool    `json:"invert,omitempty"`
+	// MinLength and MaxLength are the soft limits of the cylinder in meters,
+	// none when both are zero.
+	MinLength float64 `json:"minLength,omitempty"`
+	MaxLength float64 `json:"maxLength,omitempty"`
+}
+
+// Calibration is the calibration of every cylinder.
+type Calibration [kinematics.Legs]CylinderCalibration
+
+// DefaultCalibration returns the nominal calibration of identical cylinders.
+func DefaultCalibration() Calibration {
+	var calibration Calibration
+	for i := range calibration {
+		calibration[i] = CylinderCalibration{Offset: protocol.StrokeZero, Scale: protocol.StrokeScale}
+	}
+	return calibration
+}
+
+// Count converts a cylinder length in meters into the nearest encoder count.
+// It refuses lengths beyond the soft limits or whose count does not fit.
+func (c CylinderCalibration) Count(length float64) (int16, error) {
+	if (c.MinLength != 0 || c.MaxLength != 0) && (length < c.MinLength || length > c.MaxLength) {
+		return 0, fmt.Errorf("%w: length %v out of [%v, %v]", ErrSoftLimit, length, c.MinLength, c.MaxLength)
+	}
+	count := math.Round((length - c.Offset) * c.Scale)
+	if c.Invert {
+		count = -count
+	}
+	if math.IsNaN(count) || count < math.MinInt16 || count > math.MaxInt16 {
+		return 0, fmt.Errorf("%w: cylinder length %v is %v counts", protocol.ErrOverflow, length, count)
+	}
+	return int16(count), nil
+}
+
+// Length converts an encoder count into a cylinder length in meters.
+func (c CylinderCalibration) Length(count int16) float64 {
+	value := float64(count)
+	if c.Invert {
+		value = -value
+	}
+	return c.Offset + value/c.Scale
+}
+
+// Validate checks the scale and the soft limits of every cylinder.
+func (c Calibration) Validate() error {
+	for i, cylinder := range c {
+		if !(cylinder.Scale > 0) || math.IsInf(cylinder.Scale, 0) || math.IsNaN(cylinder.Offset) || math.IsInf(cylinder.Offset, 0) {
+			return fmt.Errorf("%w: cylinder %d offset %v scale %v", ErrInvalidCalibration, i+1, cylinder.Offset, cylinder.Scale)
+		}
+		if cylinder.MinLength > cylinder.MaxLength {
+			return fmt.Errorf("%w: cylinder %d min length is above max length", ErrInvalidCalibration, i+1)
+		}
+	}
+	return nil
+}
+
+// Positions converts cylinder lengths in meters into counts.
+func (c Calibration) Positions(lengths []float32) (protocol.Positions, error) {
+	var positions protocol.Positions
+	if len(lengths) != len(c) {
+		return positions, fmt.Errorf("%w: %d cylinder lengths, expect %d", protocol.ErrPayload, len(lengths), len(c))
+	}
+	for i, length := range lengths {
+		count, err := c[i].Count(float64(length))
+		if err != nil {
+			return positions, fmt.Errorf("cylinder %d: %w", i+1, err)
+		}
+		positions[i] = count
+	}
+	return positions, nil
+}
+
+// Lengths converts counts into cylinder lengths in meters.
+func (c Calibration) Lengths(positions protocol.Positions) []float32 {
+	lengths := make([]float32, len(positions))
+	for i, count := range positions {
+		lengths[i] = float32(c[i].Length(count))
+	}
+	return lengths
+}
+
+// storedOffsets is the file keeping the calibrated offsets across restarts.
+type storedOffsets struct {
+	Offsets [kinematics.Legs]float64 `json:"offsets"`
+	Updated time.Time                `json:"updated"`
+}
+
+// loadOffsets applies the offsets stored in path to calibration. A missing
+// file leaves calibration as configured.
+func loadOffsets(path string, calibration *Calibration) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var stored storedOffsets
+	if err = json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("calibration file %s: %w", path, err)
+	}
+	for i := range calibration {
+		calibration[i].Offset = stored.Offsets[i]
+	}
+	klog.V(1).Infof("Cylinder offsets of %s loaded from %s", stored.Updated.Format(time.RFC3339), path)
+	return nil
+}
+
+// saveOffsets stores the offsets of calibration in path, replacing the file
+// only once the new one is written.
+func saveOffsets(path string, calibration Calibration) error {
+	stored := storedOffsets{Updated: time.Now()}
+	for i := range calibration {
+		stored.Offsets[i] = calibration[i].Offset
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = temp.Write(append(data, '\n')); err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+// Calibration returns the calibration of the cylinders.
+func (c *DigitalbowClient) Calibration() Calibration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calibration
+}
+
+// CalibrateOffsets applies the measured length of every cylinder at count
+// zero, and stores them in the calibration file when the device has one. The
+// file is written without holding the client, and no execution begins
+// meanwhile.
+func (c *DigitalbowClient) CalibrateOffsets(offsets []float64) (Calibration, error) {
+	c.mu.Lock()
+	calibration := c.calibration
+	if len(offsets) != len(calibration) {
+		c.mu.Unlock()
+		return calibration, fmt.Errorf("%w: %d offsets, expect %d", ErrInvalidCalibration, len(offsets), len(calibration))
+	}
+	if c.execution != nil || c.calibrating {
+		c.mu.Unlock()
+		return calibration, ErrCalibrationBusy
+	}
+	for i, offset := range offsets {
+		calibration[i].Offset = offset
+	}
+	if err := calibration.Validate(); err != nil {
+		c.mu.Unlock()
+		return c.calibration, err
+	}
+	c.calibrating = true
+	c.mu.Unlock()
+
+	var err error
+	if path := c.Client.Config.CalibrationFile; path != "" {
+		err = saveOffsets(path, calibration)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calibrating = false
+	if err != nil {
+		return c.calibration, fmt.Errorf("store the calibration: %w", err)
+	}
+	c.calibration = calibration
+	klog.V(1).Infof("Cylinder offsets calibrated to %v", offsets)
+	return calibration, nil
+}
@@ -0,0 +1,115 @@
+package driver
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+func TestCylinderCalibration(t *testing.T) {
+	cylinder := CylinderCalibration{Offset: 0.15, Scale: 50000, Invert: true, MinLength: 0.14, MaxLength: 0.17}
+	count, err := cylinder.Count(0.16)
+	require.NoError(t, err)
+	assert.Equal(t, int16(-500), count)
+	assert.InDelta(t, 0.16, cylinder.Length(count), 1e-9)
+
+	_, err = cylinder.Count(0.171)
+	assert.True(t, errors.Is(err, ErrSoftLimit))
+	cylinder.MinLength, cylinder.MaxLength = 0, 0
+	_, err = cylinder.Count(1)
+	assert.True(t, errors.Is(err, protocol.ErrOverflow))
+
+	// The nominal calibration encodes like the protocol.
+	nominal := DefaultCalibration()[0]
+	for _, length := range []float64{protocol.StrokeZero, protocol.StrokeZero + 0.01, protocol.StrokeZero - 0.0123} {
+		want, err := protocol.Count(length)
+		require.NoError(t, err)
+		got, err := nominal.Count(length)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	invalid := DefaultCalibration()
+	invalid[2].Scale = 0
+	assert.True(t, errors.Is(invalid.Validate(), ErrInvalidCalibration))
+}
+
+func TestCalibrateOffsets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cylinders.json")
+	calibration := DefaultCalibration()
+	calibration[1].Invert = true
+	calibration[5].MaxLength, calibration[5].MinLength = 0.16, 0.15
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+	client.Client.Config.CalibrationFile = path
+	client.calibration = calibration
+
+	lengths := []float32{0.1569, 0.1579, 0.1569, 0.1569, 0.1569, 0.1569}
+	frame, err := client.AssembleSerialData(lengths)
+	require.NoError(t, err)
+	decoded, _, err := protocol.Decode(frame)
+	require.NoError(t, err)
+	positions, err := protocol.ParsePositions(decoded.Payload)
+	require.NoError(t, err)
+	assert.Equal(t, protocol.Positions{0, -40, 0, 0, 0, 0}, positions)
+
+	offsets := []float64{0.1569, 0.1569, 0.1579, 0.1569, 0.1569, 0.1564}
+	_, err = client.CalibrateOffsets(offsets[:3])
+	assert.True(t, errors.Is(err, ErrInvalidCalibration))
+	_, _, ok := client.BeginExecution("open", 0, 0)
+	require.True(t, ok)
+	_, err = client.CalibrateOffsets(offsets)
+	assert.Equal(t, ErrCalibrationBusy, err)
+	client.EndExecution()
+
+	// While offsets are stored, the status is free but nothing else starts.
+	client.calibrating = true
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+	_, _, ok = client.BeginExecution("open", 0, 0)
+	assert.False(t, ok)
+	_, err = client.CalibrateOffsets(offsets)
+	assert.Equal(t, ErrCalibrationBusy, err)
+	client.calibrating = false
+
+	applied, err := client.CalibrateOffsets(offsets)
+	require.NoError(t, err)
+	assert.Equal(t, 0.1579, applied[2].Offset)
+	assert.True(t, applied[1].Invert)
+	frame, err = client.AssembleSerialData(lengths)
+	require.NoError(t, err)
+	decoded, _, err = protocol.Decode(frame)
+	require.NoError(t, err)
+	positions, err = protocol.ParsePositions(decoded.Payload)
+	require.NoError(t, err)
+	assert.Equal(t, protocol.Positions{0, -40, -40, 0, 0, 20}, positions)
+
+	_, err = client.AssembleSerialData([]float32{0.1569, 0.1569, 0.1569, 0.1569, 0.1569, 0.17})
+	assert.True(t, errors.Is(err, ErrSoftLimit))
+
+	// The stored offsets override the configured ones on the next start.
+	reloaded := DefaultCalibration()
+	require.NoError(t, loadOffsets(path, &reloaded))
+	for i := range offsets {
+		assert.Equal(t, offsets[i], reloaded[i].Offset)
+	}
+	assert.False(t, reloaded[1].Invert)
+	missing := DefaultCalibration()
+	require.NoError(t, loadOffsets(filepath.Join(t.TempDir(), "missing.json"), &missing))
+	assert.Equal(t, DefaultCalibration(), missing)
+}
+
+func TestCylinderCountRoundTrip(t *testing.T) {
+	calibration := CylinderCalibration{Offset: protocol.StrokeZero, Scale: protocol.StrokeScale, Invert: true}
+	for count := -800; count <= 800; count++ {
+		length := float32(calibration.Length(int16(count)))
+		encoded, err := calibration.Count(float64(length))
+		assert.NoError(t, err)
+		assert.Equal(t, int16(count), encoded, "length %v", length)
+	}
+}
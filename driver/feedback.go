@@ -0,0 +1,183 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/capture"
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// positionsPublishInterval limits how often the measured positions are
+// published; the controller reports them at the playback rate.
+const positionsPublishInterval = 200 * time.Millisecond
+
+// feedbackIdle is the pause after a read returned no data.
+const feedbackIdle = 10 * time.Millisecond
+
+// Feedback is the last state reported by the controller. The bow controller
+// protocol has no feedback yet: the positions, status, version and
+// acknowledgement frames are protocol extensions which only the simulator
+// sends, and they are only read when the protocol extensions are enabled.
+type Feedback struct {
+	// Positions are the measured cylinder lengths in meters.
+	Positions []float32 `json:"positions,omitempty"`
+	// State and Fault are the last controller status.
+	State byte `json:"state"`
+	Fault byte `json:"fault"`
+	// Version is the controller version answered to the last handshake.
+	Version *protocol.Version `json:"version,omitempty"`
+	// LastAck is the last acknowledged command and its result code.
+	LastAck *protocol.Ack `json:"lastAck,omitempty"`
+	// BadFrames counts the frames dropped for a bad checksum or payload.
+	BadFrames int       `json:"badFrames"`
+	Updated   time.Time `json:"updated"`
+}
+
+// SetTwinPublisher sets where the controller feedback twin properties are
+// published.
+func (c *DigitalbowClient) SetTwinPublisher(publisher DataPublisher) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.twinPublisher = publisher
+}
+
+// Feedback returns the last state reported by the controller.
+func (c *DigitalbowClient) Feedback() Feedback {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	feedback := c.feedback
+	feedback.Positions = append([]float32(nil), c.feedback.Positions...)
+	return feedback
+}
+
+// readFeedback reads the protocol extension frames of the controller until the
+// session is closed.
+func (c *DigitalbowClient) readFeedback(session *Session) {
+	decoder := protocol.NewDecoder(session)
+	for {
+		frame, err := decoder.ReadFrame()
+		select {
+		case <-session.done:
+			return
+		default:
+		}
+		switch {
+		case err == nil:
+			c.handleFeedback(frame)
+		case errors.Is(err, ErrDisconnected):
+			time.Sleep(session.backoff.Initial)
+		case errors.Is(err, protocol.ErrChecksum):
+			c.badFrame(err)
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			// A read timeout; do not spin on a port returning no data.
+			time.Sleep(feedbackIdle)
+		default:
+			klog.V(2).Infof("Read controller feedback: %v", err)
+		}
+	}
+}
+
+func (c *DigitalbowClient) badFrame(err error) {
+	c.mu.Lock()
+	c.feedback.BadFrames++
+	c.mu.Unlock()
+	klog.V(2).Infof("Dropped controller frame: %v", err)
+}
+
+// handleFeedback applies one controller frame.
+func (c *DigitalbowClient) handleFeedback(frame protocol.Frame) {
+	if data, err := frame.Encode(); err == nil {
+		c.record(capture.Rx, data)
+	}
+	message, err := protocol.ParseExtension(frame)
+	if err != nil {
+		c.badFrame(err)
+		return
+	}
+	switch m := message.(type) {
+	case protocol.Positions:
+		c.mu.Lock()
+		c.feedback.Positions = c.calibration.Lengths(m)
+		c.feedback.Updated = time.Now()
+		publish := time.Since(c.positionsPublished) >= positionsPublishInterval
+		if publish {
+			c.positionsPublished = c.feedback.Updated
+		}
+		positions := c.feedback.Positions
+		c.mu.Unlock()
+		if publish {
+			if err = c.PublishData(common.DataCylinderPositions, positions); err != nil {
+				klog.Errorf("Publish cylinder positions failed: %v", err)
+			}
+		}
+	case protocol.Status:
+		c.handleStatus(m)
+	case protocol.Version:
+		c.mu.Lock()
+		c.feedback.Version = &m
+		c.feedback.Updated = time.Now()
+		c.mu.Unlock()
+	case protocol.Ack:
+		c.mu.Lock()
+		c.feedback.LastAck = &m
+		c.feedback.Updated = time.Now()
+		c.mu.Unlock()
+		if m.Code != 0 {
+			klog.Warningf("Controller refused %v with code %d", m.Command, m.Code)
+		}
+	}
+}
+
+// handleStatus moves the device to Fault on a controller fault, and back to
+// Ready once the fault is cleared unless the platform failed to home.
+func (c *DigitalbowClient) handleStatus(status protocol.Status) {
+	faulted := status.Fault != 0 || status.State == protocol.StateFault
+	c.mu.Lock()
+	changed := status.Fault != c.feedback.Fault
+	c.feedback.State, c.feedback.Fault = status.State, status.Fault
+	c.feedback.Updated = time.Now()
+	previous := c.Status
+	reason := fmt.Sprintf("controller state %d fault %d", status.State, status.Fault)
+	var err error
+	if faulted {
+		err = c.setStatus(common.StatusFault, reason)
+	} else if c.Status == common.StatusFault && c.homingError == nil {
+		err = c.setStatus(common.StatusReady, reason)
+	}
+	current, publisher := c.Status, c.twinPublisher
+	c.mu.Unlock()
+
+	if err != nil {
+		klog.Warningf("Device status stays %s: %v", previous, err)
+	} else if current != previous {
+		klog.Warningf("Device status %s -> %s: %s", previous, current, reason)
+	}
+	if changed && publisher != nil {
+		if err := publisher(common.TwinControllerFault, "int", strconv.Itoa(int(status.Fault))); err != nil {
+			klog.Errorf("Publish controller fault failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package driver
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/capture"
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// pipePort is a serial port reading what the test writes to controller.
+type pipePort struct {
+	*io.PipeReader
+}
+
+func (p pipePort) Write(b []byte) (int, error) {
+	return ioutil.Discard.Write(b)
+}
+
+// recorder records the published properties.
+type recorder struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (r *recorder) publish(name, valueType, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[name] = value
+	return nil
+}
+
+func (r *recorder) get(name string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.values[name]
+}
+
+func writeFrame(t *testing.T, w io.Writer, frame protocol.Frame) {
+	data, err := frame.Encode()
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+}
+
+func TestReadFeedback(t *testing.T) {
+	client := newTestClient(t)
+	data, twin := &recorder{values: map[string]string{}}, &recorder{values: map[string]string{}}
+	client.SetDataPublisher(data.publish)
+	client.SetTwinPublisher(twin.publish)
+	client.Client.Config.ProtocolExtensions = true
+
+	reader, controller := io.Pipe()
+	client.connect(func() (io.ReadWriteCloser, error) { return pipePort{reader}, nil })
+	defer client.Close()
+	require.Eventually(t, client.Connected, time.Second, time.Millisecond)
+
+	positions := protocol.Positions{0, 400, -400, 0, 0, 0}
+	writeFrame(t, controller, protocol.Frame{Command: protocol.CommandPositions, Payload: positions.Payload()})
+	require.Eventually(t, func() bool { return data.get(common.DataCylinderPositions) != "" }, time.Second, time.Millisecond)
+	feedback := client.Feedback()
+	assert.InDeltaSlice(t, []float32{0.1569, 0.1669, 0.1469, 0.1569, 0.1569, 0.1569}, feedback.Positions, 1e-6)
+
+	// A corrupt frame is counted and skipped.
+	corrupt, _ := protocol.Frame{Command: protocol.CommandStatus, Payload: []byte{0, 0}}.Encode()
+	corrupt[len(corrupt)-1]++
+	_, err := controller.Write(corrupt)
+	require.NoError(t, err)
+
+	writeFrame(t, controller, protocol.Frame{Command: protocol.CommandStatus,
+		Payload: protocol.Status{State: protocol.StateFault, Fault: 3}.Payload()})
+	require.Eventually(t, func() bool { return client.GetStatus() == common.StatusFault }, time.Second, time.Millisecond)
+	assert.Equal(t, "3", twin.get(common.TwinControllerFault))
+	assert.Equal(t, 1, client.Feedback().BadFrames)
+
+	writeFrame(t, controller, protocol.Frame{Command: protocol.CommandAck,
+		Payload: protocol.Ack{Command: protocol.CommandSetPositions}.Payload()})
+	writeFrame(t, controller, protocol.Frame{Command: protocol.CommandStatus,
+		Payload: protocol.Status{State: protocol.StateIdle}.Payload()})
+	require.Eventually(t, func() bool { return client.GetStatus() == common.StatusReady }, time.Second, time.Millisecond)
+	assert.Equal(t, "0", twin.get(common.TwinControllerFault))
+	assert.Equal(t, &protocol.Ack{Command: protocol.CommandSetPositions}, client.Feedback().LastAck)
+}
+
+func TestFeedbackNeedsProtocolExtensions(t *testing.T) {
+	client := newTestClient(t)
+	reader, controller := io.Pipe()
+	client.connect(func() (io.ReadWriteCloser, error) { return pipePort{reader}, nil })
+	require.Eventually(t, client.Connected, time.Second, time.Millisecond)
+
+	// The controller protocol has no feedback, so nothing reads the port.
+	data, err := protocol.Frame{Command: protocol.CommandPositions, Payload: protocol.Positions{}.Payload()}.Encode()
+	require.NoError(t, err)
+	written := make(chan struct{})
+	go func() {
+		_, _ = controller.Write(data)
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("the port was read without the protocol extensions")
+	case <-time.After(5 * feedbackIdle):
+	}
+	assert.Empty(t, client.Feedback().Positions)
+	require.NoError(t, client.Close())
+	require.NoError(t, reader.Close())
+	<-written
+}
+
+func TestCaptureFrames(t *testing.T) {
+	client := newTestClient(t)
+	path := filepath.Join(t.TempDir(), "run.cap")
+	require.NoError(t, client.startCapture(path))
+	client.Client.Config.ProtocolExtensions = true
+
+	reader, controller := io.Pipe()
+	client.connect(func() (io.ReadWriteCloser, error) { return pipePort{reader}, nil })
+	require.Eventually(t, client.Connected, time.Second, time.Millisecond)
+
+	home, err := protocol.Frame{Address: protocol.BroadcastAddress, Command: protocol.CommandHome}.Encode()
+	require.NoError(t, err)
+	require.NoError(t, client.Write(home))
+	ack := protocol.Frame{Command: protocol.CommandAck, Payload: protocol.Ack{Command: protocol.CommandHome}.Payload()}
+	writeFrame(t, controller, ack)
+	require.Eventually(t, func() bool { return client.Feedback().LastAck != nil }, time.Second, time.Millisecond)
+	require.NoError(t, client.Close())
+
+	records, err := capture.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, capture.Tx, records[0].Direction)
+	assert.Equal(t, home, records[0].Data)
+	assert.Equal(t, capture.Rx, records[1].Direction)
+	data, _ := ack.Encode()
+	assert.Equal(t, data, records[1].Data)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Length units of the track translations.
+const (
+	UnitMillimeter = "mm"
+	UnitCentimeter = "cm"
+	UnitMeter      = "m"
+)
+
+// frameTolerance is how far a configured rotation may be from orthonormal.
+const frameTolerance = 1e-6
+
+// FrameConfig is the transform from the tracker frame U to the bow frame A.
+type FrameConfig struct {
+	// Rotation is the U->A rotation matrix.
+	Rotation [3][3]float64 `json:"rotation"`
+	// IncisalPoint is the origin of U in A, in LengthUnit.
+	IncisalPoint [3]float64 `json:"incisalPoint"`
+	// LengthUnit is the unit of the track translations and IncisalPoint.
+	LengthUnit string `json:"lengthUnit"`
+	// Source tells where the transform comes from.
+	Source string `json:"source,omitempty"`
+}
+
+// FrameReport is the frame transform in use, for auditing.
+type FrameReport struct {
+	FrameConfig
+	Transform [4][4]float64 `json:"transform"`
+}
+
+// DefaultFrameConfig returns the transform of the reference installation:
+// a 90 degree rotation about X and the incisal point 50 mm above the origin.
+func DefaultFrameConfig() FrameConfig {
+	return FrameConfig{
+		Rotation:     AxisAngleRotation([3]float64{1, 0, 0}, 90),
+		IncisalPoint: [3]float64{0, 0, 50},
+		LengthUnit:   UnitMillimeter,
+		Source:       "default",
+	}
+}
+
+// AxisAngleRotation returns the rotation of angle degrees about axis.
+func AxisAngleRotation(axis [3]float64, angle float64) [3][3]float64 {
+	n := math.Sqrt(axis[0]*axis[0] + axis[1]*axis[1] + axis[2]*axis[2])
+	x, y, z := axis[0]/n, axis[1]/n, axis[2]/n
+	rad := angle * math.Pi / 180
+	c, s := math.Cos(rad), math.Sin(rad)
+	t := 1 - c
+	return [3][3]float64{
+		{t*x*x + c, t*x*y - s*z, t*x*z + s*y},
+		{t*x*y + s*z, t*y*y + c, t*y*z - s*x},
+		{t*x*z - s*y, t*y*z + s*x, t*z*z + c},
+	}
+}
+
+// Validate checks the rotation is a proper rotation and the unit is known.
+func (f FrameConfig) Validate() error {
+	if _, err := lengthScale(f.LengthUnit); err != nil {
+		return err
+	}
+	r := mat.NewDense(3, 3, nil)
+	for i := 0; i < 3; i++ {
+		r.SetRow(i, f.Rotation[i][:])
+	}
+	var product mat.Dense
+	product.Mul(r.T(), r)
+	if !mat.EqualApprox(&product, identity(3), frameTolerance) {
+		return fmt.Errorf("frame rotation %v is not orthonormal", f.Rotation)
+	}
+	if det := mat.Det(r); math.Abs(det-1) > frameTolerance {
+		return fmt.Errorf("frame rotation determinant is %v, expect 1", det)
+	}
+	return nil
+}
+
+// Transform returns the homogeneous U->A transform.
+func (f FrameConfig) Transform() [4][4]float64 {
+	var transform [4][4]float64
+	for i := 0; i < 3; i++ {
+		copy(transform[i][:3], f.Rotation[i][:])
+		transform[i][3] = f.IncisalPoint[i]
+	}
+	transform[3][3] = 1
+	return transform
+}
+
+// matrices returns the rotation and the transform as gonum matrices.
+func (f FrameConfig) matrices() (rotation, transform *mat.Dense) {
+	rotation = mat.NewDense(3, 3, nil)
+	for i := 0; i < 3; i++ {
+		rotation.SetRow(i, f.Rotation[i][:])
+	}
+	t := f.Transform()
+	transform = mat.NewDense(4, 4, nil)
+	for i := 0; i < 4; i++ {
+		transform.SetRow(i, t[i][:])
+	}
+	return rotation, transform
+}
+
+// lengthScale returns the factor converting unit into meters.
+func lengthScale(unit string) (float64, error) {
+	switch unit {
+	case "", UnitMillimeter:
+		return 0.001, nil
+	case UnitCentimeter:
+		return 0.01, nil
+	case UnitMeter:
+		return 1, nil
+	default:
+		return 0, fmt.Errorf("unknown length unit %s", unit)
+	}
+}
+
+func identity(n int) *mat.Dense {
+	m := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		m.Set(i, i, 1)
+	}
+	return m
+}
+
+// Frame returns the frame transform in use.
+func (c *DigitalbowClient) Frame() FrameReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return FrameReport{FrameConfig: c.frame, Transform: c.frame.Transform()}
+}
+
+// SetFrame validates and applies a new frame transform.
+func (c *DigitalbowClient) SetFrame(frame FrameConfig) error {
+	if err := frame.Validate(); err != nil {
+		return err
+	}
+	scale, _ := lengthScale(frame.LengthUnit)
+	rotation, transform := frame.matrices()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frame = frame
+	c.lengthScale = scale
+	c.Rotation_AU = rotation
+	c.Transform_AU = transform
+	return nil
+}
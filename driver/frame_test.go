@@ -0,0 +1,74 @@
+package driver
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultFrameConfig(t *testing.T) {
+	frame := DefaultFrameConfig()
+	assert.NoError(t, frame.Validate())
+
+	expected := [3][3]float64{
+		{1, 0, 0},
+		{0, math.Cos(math.Pi / 2), -math.Sin(math.Pi / 2)},
+		{0, math.Sin(math.Pi / 2), math.Cos(math.Pi / 2)},
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			assert.InDelta(t, expected[i][j], frame.Rotation[i][j], 1e-12)
+		}
+	}
+	assert.Equal(t, [4]float64{0, 0, 0, 1}, frame.Transform()[3])
+	assert.Equal(t, 50.0, frame.Transform()[2][3])
+}
+
+func TestFrameConfigValidate(t *testing.T) {
+	frame := DefaultFrameConfig()
+	frame.Rotation[0][0] = 2
+	assert.Error(t, frame.Validate())
+
+	frame = DefaultFrameConfig()
+	frame.Rotation[2] = [3]float64{0, -frame.Rotation[2][1], -frame.Rotation[2][2]}
+	assert.Error(t, frame.Validate())
+
+	frame = DefaultFrameConfig()
+	frame.LengthUnit = "inch"
+	assert.Error(t, frame.Validate())
+}
+
+func TestSetFrame(t *testing.T) {
+	client := newTestClient(t)
+	identity := [4][4]float64{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+	track := [4][4]float64{{0, 0, 0, 10}, {0, 0, 0, 20}, {0, 0, 0, 30}, {0, 0, 0, 0}}
+
+	assert.NoError(t, client.SetFrame(FrameConfig{Rotation: AxisAngleRotation([3]float64{0, 0, 1}, 0), LengthUnit: UnitCentimeter}))
+	assert.Equal(t, UnitCentimeter, client.Frame().LengthUnit)
+	result := client.GetBowDataformat(track, identity)
+	assert.InDelta(t, 0.1, result[3], 1e-12)
+	assert.InDelta(t, 0.2, result[4], 1e-12)
+	assert.InDelta(t, 0.3, result[5], 1e-12)
+
+	assert.Error(t, client.SetFrame(FrameConfig{}))
+	assert.Equal(t, UnitCentimeter, client.Frame().LengthUnit)
+}
+
+func TestBowDataformatTranslation(t *testing.T) {
+	// Each translation axis lands on its own pose element; z used to
+	// overwrite y and never reach the solver.
+	client := newCompositionClient(t, CompositionRelative)
+	track := TrackData{MatrixList: [][4][4]float64{transformZ(0, 0), transformZ(0, 0)}}
+	track.MatrixList[1][0][3], track.MatrixList[1][1][3], track.MatrixList[1][2][3] = 0.01, 0.02, 0.03
+	poses := client.SegmentPoses(track)
+	assert.InDeltaSlice(t, []float64{0, 0, 0, 0.01, 0.02, 0.03}, toFloat64(poses[1]), 1e-6)
+}
+
+func toFloat64(values []float32) []float64 {
+	result := make([]float64, len(values))
+	for i, value := range values {
+		result[i] = float64(value)
+	}
+	return result
+}
@@ -0,0 +1,321 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// Defaults of the homing sequence.
+const (
+	DefaultHomingRamp       = 5 * time.Second
+	DefaultHomingTimeout    = 30 * time.Second
+	DefaultHandshakeTimeout = 2 * time.Second
+	// DefaultHomingTolerance is the largest cylinder error in meters accepted
+	// at the mechanical zero.
+	DefaultHomingTolerance = 0.0005
+)
+
+// Errors of the homing sequence.
+var (
+	ErrHomingBusy = errors.New("the device can only be homed when Ready or Fault")
+	ErrExtension  = errors.New("protocol extensions are disabled")
+	ErrHandshake  = errors.New("controller handshake failed")
+	// ErrHomingUnsupported means the controller did not answer the
+	// handshake, as a controller without the protocol extensions does.
+	ErrHomingUnsupported = errors.New("controller does not support homing")
+	ErrHomingRefused     = errors.New("controller refused to home")
+	ErrNotHomed          = errors.New("platform did not reach the mechanical zero")
+)
+
+// HomingConfig is the homing sequence of the platform.
+type HomingConfig struct {
+	// OnStart homes the platform once the serial port is open, and the device
+	// is only Ready once homed. It needs the protocol extensions; without them
+	// the device is Ready with the platform position unverified.
+	OnStart bool
+	// Ramp is how long the move to the mechanical zero takes,
+	// DefaultHomingRamp when zero.
+	Ramp time.Duration
+	// Timeout bounds the whole sequence, DefaultHomingTimeout when zero.
+	Timeout time.Duration
+	// HandshakeTimeout bounds the answer to a controller query,
+	// DefaultHandshakeTimeout when zero.
+	HandshakeTimeout time.Duration
+	// Tolerance is the largest cylinder error in meters accepted at the
+	// mechanical zero, DefaultHomingTolerance when zero.
+	Tolerance float64
+}
+
+// HomingReport is the outcome of a homing sequence.
+type HomingReport struct {
+	Reason string `json:"reason"`
+	// Version is the controller version answered to the handshake.
+	Version string    `json:"version,omitempty"`
+	Started time.Time `json:"started"`
+	Ended   time.Time `json:"ended"`
+	// Positions are the cylinder lengths measured at the end.
+	Positions []float32 `json:"positions,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Home queries the controller, drives the cylinders to the lengths of the
+// mechanical zero and confirms them through the feedback. The device is Homing
+// meanwhile, then Ready, or Fault when the sequence fails. A controller that
+// does not answer the handshake does not support homing and leaves the device
+// as it was.
+//
+// The handshake, home command and feedback are protocol extensions which only
+// the simulator answers so far. The controller protocol has no way to verify
+// the platform, so Home refuses to run unless the extensions are enabled.
+func (c *DigitalbowClient) Home(reason string) (HomingReport, error) {
+	if !c.Client.Config.ProtocolExtensions {
+		return HomingReport{}, fmt.Errorf("%w: homing needs the handshake and home commands", ErrExtension)
+	}
+	c.mu.Lock()
+	if c.execution != nil || (c.Status != common.StatusReady && c.Status != common.StatusFault) {
+		status := c.Status
+		c.mu.Unlock()
+		return HomingReport{}, fmt.Errorf("%w: device is %s", ErrHomingBusy, status)
+	}
+	previous := c.Status
+	err := c.setStatus(common.StatusHoming, reason)
+	c.mu.Unlock()
+	if err != nil {
+		return HomingReport{}, err
+	}
+	return c.runHoming(reason, previous)
+}
+
+// LastHoming returns the report of the last homing sequence, nil if none ran.
+func (c *DigitalbowClient) LastHoming() *HomingReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastHoming == nil {
+		return nil
+	}
+	report := *c.lastHoming
+	return &report
+}
+
+// runHoming runs the homing sequence of a Homing device and leaves it Ready or
+// Fault, or unsupported when the controller does not support homing.
+func (c *DigitalbowClient) runHoming(reason string, unsupported common.DeviceStatus) (HomingReport, error) {
+	config := c.homingConfig()
+	report := HomingReport{Reason: reason, Started: time.Now()}
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+	klog.V(1).Infof("Homing the platform: %s", reason)
+
+	err := c.home(ctx, config, &report)
+	report.Ended = time.Now()
+	report.Positions = c.Feedback().Positions
+	status := common.StatusReady
+	switch {
+	case errors.Is(err, ErrHomingUnsupported):
+		report.Error = err.Error()
+		status = unsupported
+	case err != nil:
+		report.Error = err.Error()
+		status = common.StatusFault
+	}
+
+	c.mu.Lock()
+	if !errors.Is(err, ErrHomingUnsupported) {
+		c.homingError = err
+		c.homed = err == nil
+	}
+	c.lastHoming = &report
+	moved := c.Status == common.StatusHoming && c.setStatus(status, "homing over") == nil
+	current, publisher := c.Status, c.twinPublisher
+	c.mu.Unlock()
+
+	if errors.Is(err, ErrHomingUnsupported) {
+		klog.Warningf("Platform not homed, device is %s: %v", current, err)
+	} else if err != nil {
+		klog.Errorf("Homing failed, device is %s: %v", current, err)
+	} else if !moved {
+		klog.Warningf("Platform homed but the device is %s", current)
+	} else {
+		klog.V(1).Infof("Platform homed in %v", report.Ended.Sub(report.Started))
+	}
+	if publishErr := c.PublishData(common.DataHoming, report); publishErr != nil {
+		klog.Errorf("Publish homing report failed: %v", publishErr)
+	}
+	if publisher != nil {
+		if publishErr := publisher(common.TwinDeviceStatus, "string", string(current)); publishErr != nil {
+			klog.Errorf("Publish device status failed: %v", publishErr)
+		}
+	}
+	return report, err
+}
+
+// home runs the steps of the homing sequence.
+func (c *DigitalbowClient) home(ctx context.Context, config HomingConfig, report *HomingReport) error {
+	// Query the controller; its answer comes with fresh positions.
+	c.mu.Lock()
+	c.feedback.Version, c.feedback.Positions = nil, nil
+	c.mu.Unlock()
+	if err := c.sendCommand(protocol.CommandHandshake); err != nil {
+		return fmt.Errorf("%w: %v", ErrHandshake, err)
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, config.HandshakeTimeout)
+	err := c.awaitFeedback(queryCtx, func(feedback Feedback) (bool, error) {
+		if feedback.Version != nil {
+			report.Version = feedback.Version.String()
+		}
+		return feedback.Version != nil && len(feedback.Positions) == kinematics.Legs, nil
+	})
+	cancel()
+	if err != nil {
+		if report.Version == "" {
+			return fmt.Errorf("%w: no handshake answer: %v", ErrHomingUnsupported, err)
+		}
+		return fmt.Errorf("%w: no position feedback: %v", ErrHandshake, err)
+	}
+	feedback := c.Feedback()
+	if feedback.Fault != 0 || feedback.State == protocol.StateFault {
+		return fmt.Errorf("%w: controller fault %d", ErrHandshake, feedback.Fault)
+	}
+
+	// Ramp slowly from the measured cylinder lengths to those of the
+	// mechanical zero, in cylinder space so that no geometry is needed.
+	zero := make([]float32, kinematics.Legs)
+	c.Client.Execute(make([]float32, kinematics.Legs), zero)
+	if err := c.rampCylinders(ctx, feedback.Positions, zero, config.Ramp, DefaultFrequency); err != nil {
+		return fmt.Errorf("%w: ramp: %v", ErrNotHomed, err)
+	}
+
+	// Let the controller reference its zero and confirm it was reached.
+	c.mu.Lock()
+	c.feedback.LastAck = nil
+	c.mu.Unlock()
+	if err := c.sendCommand(protocol.CommandHome); err != nil {
+		return fmt.Errorf("%w: %v", ErrHomingRefused, err)
+	}
+	var worst float64
+	err = c.awaitFeedback(ctx, func(feedback Feedback) (bool, error) {
+		if feedback.Fault != 0 || feedback.State == protocol.StateFault {
+			return false, fmt.Errorf("%w: controller fault %d", ErrNotHomed, feedback.Fault)
+		}
+		ack := feedback.LastAck
+		if ack == nil || ack.Command != protocol.CommandHome {
+			return false, nil
+		}
+		if ack.Code != 0 {
+			return false, fmt.Errorf("%w: code %d", ErrHomingRefused, ack.Code)
+		}
+		if feedback.State == protocol.StateHoming || feedback.State == protocol.StateMoving {
+			return false, nil
+		}
+		worst = 0
+		for i, length := range feedback.Positions {
+			worst = math.Max(worst, math.Abs(float64(length-zero[i])))
+		}
+		return len(feedback.Positions) == kinematics.Legs && worst <= config.Tolerance, nil
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %.5f m away after %v", ErrNotHomed, worst, config.Timeout)
+	}
+	return err
+}
+
+// rampCylinders moves the cylinders from their lengths from to the lengths to
+// over duration, at the playback rate or frequency Hz when the device has none.
+// Every cylinder follows the smoothstep of MoveTo.
+func (c *DigitalbowClient) rampCylinders(ctx context.Context, from, to []float32, duration time.Duration, frequency int) error {
+	if c.Client.Config.PlaybackRate > 0 {
+		frequency = c.Client.Config.PlaybackRate
+	}
+	playback := NewPlayback(frequency)
+	steps := int(math.Ceil(float64(duration) / float64(playback.Period)))
+	if steps < 1 {
+		steps = 1
+	}
+	clylen := make([]float32, len(from))
+	_, err := playback.RunContext(ctx, steps, func(step int) error {
+		s := float32(step+1) / float32(steps)
+		s = s * s * (3 - 2*s)
+		for i := range from {
+			clylen[i] = from[i] + (to[i]-from[i])*s
+		}
+		frame, err := c.AssembleSerialData(clylen)
+		if err != nil {
+			return err
+		}
+		return c.Write(frame)
+	})
+	return err
+}
+
+// sendCommand writes a command frame without payload to the controller. It
+// refuses the protocol extensions unless they are enabled.
+func (c *DigitalbowClient) sendCommand(command protocol.Command) error {
+	if command.Extension() && !c.Client.Config.ProtocolExtensions {
+		return fmt.Errorf("%w: %v", ErrExtension, command)
+	}
+	frame, err := protocol.Frame{Address: protocol.BroadcastAddress, Command: command}.Encode()
+	if err != nil {
+		return err
+	}
+	return c.Write(frame)
+}
+
+// awaitFeedback polls the controller feedback until ready reports true or an
+// error, or ctx is done.
+func (c *DigitalbowClient) awaitFeedback(ctx context.Context, ready func(Feedback) (bool, error)) error {
+	ticker := time.NewTicker(feedbackIdle)
+	defer ticker.Stop()
+	for {
+		if done, err := ready(c.Feedback()); done || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// homingConfig returns the homing configuration with its defaults.
+func (c *DigitalbowClient) homingConfig() HomingConfig {
+	config := c.Client.Config.Homing
+	if config.Ramp <= 0 {
+		config.Ramp = DefaultHomingRamp
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultHomingTimeout
+	}
+	if config.HandshakeTimeout <= 0 {
+		config.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+	if config.Tolerance <= 0 {
+		config.Tolerance = DefaultHomingTolerance
+	}
+	return config
+}
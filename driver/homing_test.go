@@ -0,0 +1,152 @@
+package driver
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+	"github.com/smilelinkd/digitalbow-mapper/simulator"
+)
+
+// connectSimulator connects client to a simulated controller.
+func connectSimulator(t *testing.T, client *DigitalbowClient) *simulator.Simulator {
+	mapper, controller := net.Pipe()
+	stop := make(chan struct{})
+	sim := simulator.New(simulator.DefaultConfig())
+	go sim.Serve(controller, stop)
+	t.Cleanup(func() {
+		client.Close()
+		close(stop)
+		controller.Close()
+	})
+	client.connect(func() (io.ReadWriteCloser, error) { return mapper, nil })
+	return sim
+}
+
+// publishedTwins records the twin properties published by a client.
+type publishedTwins struct {
+	mu     sync.Mutex
+	values map[string][]string
+}
+
+func (p *publishedTwins) publish(name, valueType, value string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.values[name] = append(p.values[name], value)
+	return nil
+}
+
+func (p *publishedTwins) get(name string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.values[name]...)
+}
+
+func TestHoming(t *testing.T) {
+	client := newTestClient(t)
+	client.Client.Config.ProtocolExtensions = true
+	client.Client.Config.Homing = HomingConfig{OnStart: true, Ramp: 100 * time.Millisecond, Timeout: 2 * time.Second}
+	// Homing ramps in cylinder space, without the forward kinematics, as with
+	// libSixDof and no configured geometry.
+	client.platform = nil
+	twins := &publishedTwins{values: map[string][]string{}}
+	client.SetTwinPublisher(twins.publish)
+	sim := connectSimulator(t, client)
+
+	require.Eventually(t, func() bool { return client.GetStatus() == common.StatusReady }, 2*time.Second, time.Millisecond)
+	report := client.LastHoming()
+	require.NotNil(t, report)
+	assert.Equal(t, "1.0", report.Version)
+	assert.Empty(t, report.Error)
+	assert.Equal(t, []string{"Ready"}, twins.get(common.TwinDeviceStatus))
+	history := client.StatusHistory()
+	require.True(t, len(history) >= 2)
+	assert.Equal(t, common.StatusHoming, history[len(history)-2].To)
+	assert.Equal(t, common.StatusReady, history[len(history)-1].To)
+
+	// Homing on demand ramps back from a displaced pose.
+	clylen := make([]float32, kinematics.Legs)
+	client.Client.Execute([]float32{0, 0, 0, 0, 0, 0.005}, clylen)
+	frame, err := client.AssembleSerialData(clylen)
+	require.NoError(t, err)
+	require.NoError(t, client.Write(frame))
+	require.Eventually(t, func() bool {
+		return sim.State().Positions[0]-protocol.StrokeZero > 0.004
+	}, 2*time.Second, time.Millisecond)
+	report2, err := client.Home("test")
+	require.NoError(t, err)
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+	for _, length := range sim.State().Positions {
+		assert.InDelta(t, protocol.StrokeZero, length, DefaultHomingTolerance)
+	}
+	assert.Len(t, report2.Positions, kinematics.Legs)
+
+	// A failed homing holds the device in Fault until it is homed.
+	sim.InjectFault(3)
+	require.Eventually(t, func() bool { return client.GetStatus() == common.StatusFault }, time.Second, time.Millisecond)
+	_, err = client.Home("test")
+	assert.True(t, errors.Is(err, ErrHandshake))
+	sim.ClearFault()
+	require.Eventually(t, func() bool { return client.Feedback().Fault == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, common.StatusFault, client.GetStatus())
+	assert.Contains(t, twins.get(common.TwinDeviceStatus), "Fault")
+	_, err = client.Home("test")
+	require.NoError(t, err)
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+
+	require.NoError(t, client.Transition(common.StatusLocked, "maintenance"))
+	_, err = client.Home("test")
+	assert.True(t, errors.Is(err, ErrHomingBusy))
+}
+
+func TestHomingWithoutController(t *testing.T) {
+	client := newTestClient(t)
+	client.Client.Config.ProtocolExtensions = true
+	client.Client.Config.Homing = HomingConfig{OnStart: true, HandshakeTimeout: 20 * time.Millisecond}
+	opener := &fakeOpener{}
+	client.connect(opener.open)
+	defer client.Close()
+
+	// A controller that never answers the handshake does not support homing;
+	// the device is Ready unhomed rather than held in Fault.
+	require.Eventually(t, func() bool { return client.LastHoming() != nil }, time.Second, time.Millisecond)
+	report := client.LastHoming()
+	assert.Contains(t, report.Error, ErrHomingUnsupported.Error())
+	require.Eventually(t, func() bool { return client.GetStatus() == common.StatusReady }, time.Second, time.Millisecond)
+
+	// Homing on demand leaves a faulted device faulted.
+	require.NoError(t, client.Transition(common.StatusFault, "controller fault"))
+	_, err := client.Home("test")
+	assert.True(t, errors.Is(err, ErrHomingUnsupported))
+	assert.Equal(t, common.StatusFault, client.GetStatus())
+	_, err = client.Home("test")
+	assert.True(t, errors.Is(err, ErrHomingUnsupported))
+	require.NoError(t, client.Transition(common.StatusReady, "fault cleared"))
+	_, _, ok := client.BeginExecution("open", 0, 0)
+	assert.True(t, ok)
+}
+
+func TestHomingNeedsProtocolExtensions(t *testing.T) {
+	client := newTestClient(t)
+	client.Client.Config.Homing = HomingConfig{OnStart: true}
+	opener := &fakeOpener{}
+	client.connect(opener.open)
+	defer client.Close()
+
+	// The controller is never sent a command beyond the controller protocol.
+	require.Eventually(t, func() bool { return client.GetStatus() == common.StatusReady }, time.Second, time.Millisecond)
+	_, err := client.Home("test")
+	assert.True(t, errors.Is(err, ErrExtension))
+	assert.True(t, errors.Is(client.sendCommand(protocol.CommandHandshake), ErrExtension))
+	assert.Nil(t, client.LastHoming())
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+}
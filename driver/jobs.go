@@ -0,0 +1,324 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// maxJobHistory is how many finished jobs are kept for queries.
+const maxJobHistory = 100
+
+// jobRetry is how often the next job tries to start on a device that is not
+// Ready.
+const jobRetry = 50 * time.Millisecond
+
+// JobState is the state of an execution job.
+type JobState string
+
+// Job states. A job is queued, then running, then ends in one of the others.
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobStopped   JobState = "stopped"
+	JobCancelled JobState = "cancelled"
+)
+
+// Errors of the job queue.
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrJobNotQueued    = errors.New("only a queued job can be cancelled, stop a running one")
+	ErrJobNothingToRun = errors.New("job has nothing to run")
+)
+
+// JobSpec is an execution to queue.
+type JobSpec struct {
+	Segment string
+	// Frames and Frequency are the pass of a segment playback, see
+	// BeginExecution.
+	Frames    int
+	Frequency int
+	// Indexes are the segment frames of the frames of the pass, see
+	// PlaybackOptions.Indexes. The pass plays the segment in order when nil.
+	Indexes []int
+	// Run plays the execution. It returns the error of ctx once stopped.
+	Run func(ctx context.Context, control *PlaybackControl) error
+}
+
+// Job is an execution submitted to the device queue.
+type Job struct {
+	ID      string   `json:"id"`
+	Segment string   `json:"segment,omitempty"`
+	State   JobState `json:"state"`
+	// Pass, Frame and Frames are the progress of the playback, see
+	// ExecutionStatus.
+	Pass      int        `json:"pass"`
+	Frame     int        `json:"frame"`
+	Frames    int        `json:"frames,omitempty"`
+	Submitted time.Time  `json:"submitted"`
+	Started   *time.Time `json:"started,omitempty"`
+	Ended     *time.Time `json:"ended,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	// Stop tells why a stopped job was stopped, or a queued job cancelled by
+	// the stop of the one running.
+	Stop *StopRecord `json:"stop,omitempty"`
+}
+
+// job is a queued job and what it runs.
+type job struct {
+	Job
+	spec JobSpec
+}
+
+// jobQueue is the FIFO of the jobs of a device, oldest first.
+type jobQueue struct {
+	mu      sync.Mutex
+	jobs    []*job
+	working bool
+}
+
+// SubmitJob queues an execution. The jobs of a device run one after the
+// other; each one waits queued until the device is Ready, and can be
+// cancelled meanwhile.
+func (c *DigitalbowClient) SubmitJob(spec JobSpec) (Job, error) {
+	if spec.Run == nil {
+		return Job{}, ErrJobNothingToRun
+	}
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+	queued := &job{
+		Job:  Job{ID: id, Segment: spec.Segment, State: JobQueued, Frames: spec.Frames, Submitted: time.Now()},
+		spec: spec,
+	}
+
+	q := &c.jobs
+	q.mu.Lock()
+	q.jobs = append(q.jobs, queued)
+	start := !q.working
+	q.working = true
+	snapshot := queued.Job
+	q.mu.Unlock()
+
+	klog.V(1).Infof("Job %s queued for segment %q", id, spec.Segment)
+	if start {
+		go c.runJobs()
+	}
+	return snapshot, nil
+}
+
+// Job returns the job with the given ID.
+func (c *DigitalbowClient) Job(id string) (Job, error) {
+	q := &c.jobs
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.jobs {
+		if j.ID == id {
+			return c.jobSnapshot(j), nil
+		}
+	}
+	return Job{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+}
+
+// Jobs returns the queued, running and recently finished jobs, oldest first.
+func (c *DigitalbowClient) Jobs() []Job {
+	q := &c.jobs
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		jobs = append(jobs, c.jobSnapshot(j))
+	}
+	return jobs
+}
+
+// CancelJob removes a queued job from the queue.
+func (c *DigitalbowClient) CancelJob(id string) (Job, error) {
+	q := &c.jobs
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.jobs {
+		if j.ID != id {
+			continue
+		}
+		if j.State != JobQueued {
+			return c.jobSnapshot(j), ErrJobNotQueued
+		}
+		now := time.Now()
+		j.State, j.Ended, j.spec = JobCancelled, &now, JobSpec{}
+		snapshot := j.Job
+		q.prune()
+		klog.V(1).Infof("Job %s cancelled", id)
+		return snapshot, nil
+	}
+	return Job{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+}
+
+// cancelQueued cancels every queued job because of the stop. q.mu must be
+// held.
+func (q *jobQueue) cancelQueued(stop *StopRecord) {
+	now := time.Now()
+	for _, j := range q.jobs {
+		if j.State != JobQueued {
+			continue
+		}
+		record := *stop
+		j.State, j.Ended, j.spec, j.Stop = JobCancelled, &now, JobSpec{}, &record
+		j.Error = fmt.Sprintf("cancelled by the stop of %s: %s", stop.By, stop.Reason)
+		klog.V(1).Infof("Job %s cancelled by the stop", j.ID)
+	}
+	q.prune()
+}
+
+// jobSnapshot copies a job, with the live progress of a running one. q.mu
+// must be held.
+func (c *DigitalbowClient) jobSnapshot(j *job) Job {
+	snapshot := j.Job
+	if j.State == JobRunning {
+		status := c.ExecutionStatus()
+		snapshot.Pass, snapshot.Frame = status.Pass, status.Frame
+	}
+	return snapshot
+}
+
+// runJobs runs the queued jobs until the queue is empty. The next job only
+// leaves the queue once the device is Ready to run it.
+func (c *DigitalbowClient) runJobs() {
+	q := &c.jobs
+	ticker := time.NewTicker(jobRetry)
+	defer ticker.Stop()
+	var waiting *job
+	for {
+		q.mu.Lock()
+		var next *job
+		for _, j := range q.jobs {
+			if j.State == JobQueued {
+				next = j
+				break
+			}
+		}
+		if next == nil {
+			q.working = false
+			q.mu.Unlock()
+			return
+		}
+		spec := next.spec
+		ctx, control, ok := c.beginExecution(spec.Segment, spec.Frames, spec.Frequency, spec.Indexes)
+		if !ok {
+			q.mu.Unlock()
+			if waiting != next {
+				waiting = next
+				klog.V(1).Infof("Job %s waits for the device, now %s", next.ID, c.GetStatus())
+			}
+			<-ticker.C
+			continue
+		}
+		now := time.Now()
+		next.State, next.Started = JobRunning, &now
+		q.mu.Unlock()
+
+		c.runJob(next, ctx, control)
+	}
+}
+
+// runJob runs one job whose execution has begun and records how it ended.
+func (c *DigitalbowClient) runJob(j *job, ctx context.Context, control *PlaybackControl) {
+	state, message := JobSucceeded, ""
+	var stop *StopRecord
+
+	err := j.spec.Run(ctx, control)
+	status := c.ExecutionStatus()
+	c.mu.Lock()
+	if c.execution != nil && c.execution.stop != nil {
+		record := *c.execution.stop
+		stop = &record
+	}
+	c.mu.Unlock()
+	c.EndExecution()
+	switch {
+	case stop != nil && errors.Is(err, context.Canceled):
+		state, message = JobStopped, fmt.Sprintf("stopped by %s: %s", stop.By, stop.Reason)
+	case err != nil:
+		state, message = JobFailed, err.Error()
+	}
+
+	q := &c.jobs
+	q.mu.Lock()
+	now := time.Now()
+	j.State, j.Ended, j.Error, j.Stop = state, &now, message, stop
+	j.Pass, j.Frame = status.Pass, status.Frame
+	// Let the poses of the finished job go.
+	j.spec = JobSpec{}
+	q.prune()
+	q.mu.Unlock()
+	if state == JobFailed {
+		klog.Errorf("Job %s failed: %s", j.ID, message)
+	} else {
+		klog.V(1).Infof("Job %s %s", j.ID, state)
+	}
+}
+
+// prune forgets the oldest finished jobs beyond maxJobHistory. q.mu must be
+// held.
+func (q *jobQueue) prune() {
+	finished := 0
+	for _, j := range q.jobs {
+		if j.Ended != nil {
+			finished++
+		}
+	}
+	kept := q.jobs[:0]
+	for _, j := range q.jobs {
+		if j.Ended != nil && finished > maxJobHistory {
+			finished--
+			continue
+		}
+		kept = append(kept, j)
+	}
+	q.jobs = kept
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// AcceptsJobs reports whether a job submitted now may run: the device is
+// Ready, or busy with a job it will finish first.
+func (c *DigitalbowClient) AcceptsJobs() bool {
+	switch c.GetStatus() {
+	case common.StatusReady, common.StatusExecucting, common.StatusPaused:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+func waitJob(t *testing.T, client *DigitalbowClient, id string, state JobState) Job {
+	var job Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = client.Job(id)
+		require.NoError(t, err)
+		return job.State == state
+	}, time.Second, time.Millisecond, "job %s never %s", id, state)
+	return job
+}
+
+func TestJobQueue(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+
+	_, err := client.SubmitJob(JobSpec{})
+	assert.Equal(t, ErrJobNothingToRun, err)
+
+	release := make(chan struct{})
+	blocking := func(ctx context.Context, control *PlaybackControl) error {
+		client.ExecutionProgress(3)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	first, err := client.SubmitJob(JobSpec{Segment: "open", Frames: 10, Frequency: 10, Run: blocking})
+	require.NoError(t, err)
+	second, err := client.SubmitJob(JobSpec{Segment: "close", Run: func(context.Context, *PlaybackControl) error {
+		return errors.New("port closed")
+	}})
+	require.NoError(t, err)
+	third, err := client.SubmitJob(JobSpec{Segment: "chew", Run: blocking})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	running := waitJob(t, client, first.ID, JobRunning)
+	assert.NotNil(t, running.Started)
+	assert.Equal(t, 3, running.Frame)
+	queued, err := client.Job(second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobQueued, queued.State)
+
+	cancelled, err := client.CancelJob(third.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobCancelled, cancelled.State)
+	_, err = client.CancelJob(first.ID)
+	assert.Equal(t, ErrJobNotQueued, err)
+	_, err = client.CancelJob("missing")
+	assert.True(t, errors.Is(err, ErrJobNotFound))
+
+	close(release)
+	done := waitJob(t, client, first.ID, JobSucceeded)
+	assert.NotNil(t, done.Ended)
+	assert.Equal(t, 3, done.Frame)
+	failed := waitJob(t, client, second.ID, JobFailed)
+	assert.Equal(t, "port closed", failed.Error)
+	cancelled, err = client.Job(third.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobCancelled, cancelled.State)
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+}
+
+func TestJobStopped(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+	job, err := client.SubmitJob(JobSpec{Segment: "open", Run: func(ctx context.Context, _ *PlaybackControl) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	require.NoError(t, err)
+	waitJob(t, client, job.ID, JobRunning)
+	_, err = client.Stop("collision", "operator")
+	require.NoError(t, err)
+	stopped := waitJob(t, client, job.ID, JobStopped)
+	assert.Equal(t, "stopped by operator: collision", stopped.Error)
+	require.NotNil(t, stopped.Stop)
+	assert.Equal(t, "collision", stopped.Stop.Reason)
+
+	// A job waits queued until the device is Ready, and can be cancelled
+	// meanwhile.
+	require.NoError(t, client.Transition(common.StatusOffline, "port lost"))
+	assert.False(t, client.AcceptsJobs())
+	noop := func(context.Context, *PlaybackControl) error { return nil }
+	cancelled, err := client.SubmitJob(JobSpec{Run: noop})
+	require.NoError(t, err)
+	job, err = client.SubmitJob(JobSpec{Run: noop})
+	require.NoError(t, err)
+	time.Sleep(3 * jobRetry)
+	queued, err := client.Job(cancelled.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobQueued, queued.State)
+	_, err = client.CancelJob(cancelled.ID)
+	require.NoError(t, err)
+	require.NoError(t, client.Transition(common.StatusReady, "port open"))
+	waitJob(t, client, job.ID, JobSucceeded)
+	queued, err = client.Job(cancelled.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobCancelled, queued.State)
+}
+
+func TestStopCancelsQueuedJobs(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+	first, err := client.SubmitJob(JobSpec{Segment: "open", Run: func(ctx context.Context, _ *PlaybackControl) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	require.NoError(t, err)
+	ran := make(chan struct{}, 1)
+	second, err := client.SubmitJob(JobSpec{Segment: "close", Run: func(context.Context, *PlaybackControl) error {
+		ran <- struct{}{}
+		return nil
+	}})
+	require.NoError(t, err)
+	waitJob(t, client, first.ID, JobRunning)
+
+	_, err = client.Stop("collision", "operator")
+	require.NoError(t, err)
+	waitJob(t, client, first.ID, JobStopped)
+	require.Eventually(t, func() bool { return client.GetStatus() == common.StatusReady }, time.Second, time.Millisecond)
+
+	// The platform stays at rest once Ready again.
+	time.Sleep(3 * jobRetry)
+	select {
+	case <-ran:
+		t.Fatal("the queued job ran after the stop")
+	default:
+	}
+	cancelled, err := client.Job(second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobCancelled, cancelled.State)
+	assert.Nil(t, cancelled.Started)
+	assert.Equal(t, "cancelled by the stop of operator: collision", cancelled.Error)
+	require.NotNil(t, cancelled.Stop)
+	assert.Equal(t, "collision", cancelled.Stop.Reason)
+}
+
+func TestJobHistory(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+	var last Job
+	for i := 0; i < maxJobHistory+5; i++ {
+		var err error
+		last, err = client.SubmitJob(JobSpec{Run: func(context.Context, *PlaybackControl) error { return nil }})
+		require.NoError(t, err)
+	}
+	waitJob(t, client, last.ID, JobSucceeded)
+	assert.Len(t, client.Jobs(), maxJobHistory)
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kinematics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	// forwardIterations bounds the Newton-Raphson iterations.
+	forwardIterations = 50
+	// forwardTolerance is the largest cylinder length residual, in meters,
+	// accepted as converged.
+	forwardTolerance = 1e-9
+	// jacobianStep is the finite difference step for every pose component.
+	jacobianStep = 1e-6
+)
+
+// ErrNotConverged is returned when the forward kinematics does not converge.
+var ErrNotConverged = errors.New("forward kinematics did not converge")
+
+// ForwardKinematics solves the pose that produces the given cylinder lengths
+// with Newton-Raphson, starting from guess. The home pose is a good guess for
+// a single frame; the previous pose is a better one along a trajectory.
+func (p *Platform) ForwardKinematics(cylinders [Legs]float64, guess Pose) (Pose, error) {
+	pose := guess
+	for iteration := 0; iteration < forwardIterations; iteration++ {
+		residual := p.residual(pose, cylinders)
+		if maxAbs(residual) < forwardTolerance {
+			return pose, nil
+		}
+
+		jacobian := p.jacobian(pose)
+		var step mat.VecDense
+		if err := step.SolveVec(jacobian, mat.NewVecDense(Legs, residual[:])); err != nil {
+			return pose, fmt.Errorf("singular jacobian at %v: %v", pose, err)
+		}
+		for i := range pose {
+			pose[i] -= step.AtVec(i)
+		}
+		if hasNaN(pose) {
+			return guess, ErrNotConverged
+		}
+	}
+	if maxAbs(p.residual(pose, cylinders)) < forwardTolerance {
+		return pose, nil
+	}
+	return pose, ErrNotConverged
+}
+
+// residual returns the cylinder lengths of pose minus the target lengths.
+func (p *Platform) residual(pose Pose, cylinders [Legs]float64) [Legs]float64 {
+	lengths := p.CylinderLengths(pose)
+	for i := range lengths {
+		lengths[i] -= cylinders[i]
+	}
+	return lengths
+}
+
+// jacobian returns d(cylinder length)/d(pose) by central differences.
+func (p *Platform) jacobian(pose Pose) *mat.Dense {
+	jacobian := mat.NewDense(Legs, Legs, nil)
+	for col := 0; col < Legs; col++ {
+		forward, backward := pose, pose
+		forward[col] += jacobianStep
+		backward[col] -= jacobianStep
+		high := p.CylinderLengths(forward)
+		low := p.CylinderLengths(backward)
+		for row := 0; row < Legs; row++ {
+			jacobian.Set(row, col, (high[row]-low[row])/(2*jacobianStep))
+		}
+	}
+	return jacobian
+}
+
+func maxAbs(values [Legs]float64) float64 {
+	var max float64
+	for _, value := range values {
+		max = math.Max(max, math.Abs(value))
+	}
+	return max
+}
+
+func hasNaN(pose Pose) bool {
+	for _, value := range pose {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package kinematics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardKinematicsRoundTrip(t *testing.T) {
+	platform, err := NewPlatform(testGeometry())
+	assert.NoError(t, err)
+
+	poses := []Pose{
+		{},
+		{2, 0, 0, 0, 0, 0},
+		{-5, 5, -5, 0.01, -0.01, 0.01},
+		{5, -5, 5, -0.01, 0.01, -0.01},
+		{0, 0, 10, 0, 0, -0.02},
+	}
+	for _, pose := range poses {
+		solved, err := platform.ForwardKinematics(platform.CylinderLengths(pose), Pose{})
+		assert.NoError(t, err)
+		for i := range pose {
+			assert.InDelta(t, pose[i], solved[i], 1e-6, "pose %v component %d", pose, i)
+		}
+	}
+}
+
+func TestForwardKinematicsUnreachable(t *testing.T) {
+	platform, err := NewPlatform(testGeometry())
+	assert.NoError(t, err)
+
+	var cylinders [Legs]float64
+	for i := range cylinders {
+		cylinders[i] = 10
+	}
+	cylinders[0] = -10
+	_, err = platform.ForwardKinematics(cylinders, Pose{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kinematics implements the Stewart platform kinematics of the bow.
+//
+// A pose is laid out the same way the bow controller expects it:
+// roll, pitch, yaw in degrees followed by x, y, z in meters. Rotations are
+// applied in Z-Y-X order (yaw, then pitch, then roll).
+package kinematics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Legs is the number of actuators of the platform.
+const Legs = 6
+
+// Pose is roll, pitch, yaw (degrees) and x, y, z (meters).
+type Pose [Legs]float64
+
+// Vector is a point or direction in platform coordinates, in meters.
+type Vector [3]float64
+
+// Geometry describes the joint layout of the platform. There is no default:
+// every installation measures and configures its own.
+type Geometry struct {
+	// BaseJoints are the lower joint centers in the base frame.
+	BaseJoints [Legs]Vector
+	// PlatformJoints are the upper joint centers in the moving platform frame.
+	PlatformJoints [Legs]Vector
+	// LegLength is the joint to joint length of every leg at the home pose.
+	LegLength float64
+	// HomeHeight is the height of the platform origin above the base origin at
+	// the home pose. It is derived from LegLength when zero.
+	HomeHeight float64
+	// HomeStroke is the cylinder length reported for a leg at the home pose.
+	HomeStroke float64
+}
+
+// HexagonJoints places joint pairs on a circle of the given radius. Each pair is
+// centered on 0, 120 and 240 degrees and spread by ±spread degrees.
+func HexagonJoints(radius, spread float64) [Legs]Vector {
+	var joints [Legs]Vector
+	for i := 0; i < Legs; i++ {
+		center := float64(i/2) * 120
+		angle := center - spread
+		if i%2 == 1 {
+			angle = center + spread
+		}
+		rad := angle * math.Pi / 180
+		joints[i] = Vector{radius * math.Cos(rad), radius * math.Sin(rad), 0}
+	}
+	return joints
+}
+
+// Platform solves the kinematics of one platform geometry.
+type Platform struct {
+	geometry Geometry
+}
+
+// NewPlatform validates the geometry and returns a solver for it.
+func NewPlatform(geometry Geometry) (*Platform, error) {
+	if geometry.BaseJoints == ([Legs]Vector{}) || geometry.PlatformJoints == ([Legs]Vector{}) {
+		return nil, errors.New("base and platform joints are required")
+	}
+	if geometry.LegLength <= 0 {
+		return nil, errors.New("leg length must be positive")
+	}
+	if geometry.HomeHeight == 0 {
+		var sum float64
+		for i := 0; i < Legs; i++ {
+			dx := geometry.PlatformJoints[i][0] - geometry.BaseJoints[i][0]
+			dy := geometry.PlatformJoints[i][1] - geometry.BaseJoints[i][1]
+			horizontal := dx*dx + dy*dy
+			if horizontal >= geometry.LegLength*geometry.LegLength {
+				return nil, fmt.Errorf("leg %d can not reach the platform with length %v", i+1, geometry.LegLength)
+			}
+			sum += math.Sqrt(geometry.LegLength*geometry.LegLength-horizontal) -
+				geometry.PlatformJoints[i][2] + geometry.BaseJoints[i][2]
+		}
+		geometry.HomeHeight = sum / Legs
+	}
+	if geometry.HomeHeight <= 0 {
+		return nil, errors.New("home height must be positive")
+	}
+	return &Platform{geometry: geometry}, nil
+}
+
+// Geometry returns the geometry used by the platform, with HomeHeight resolved.
+func (p *Platform) Geometry() Geometry {
+	return p.geometry
+}
+
+// RotationMatrix returns the Z-Y-X rotation for roll, pitch and yaw in degrees.
+func RotationMatrix(roll, pitch, yaw float64) [3][3]float64 {
+	r := roll * math.Pi / 180
+	p := pitch * math.Pi / 180
+	y := yaw * math.Pi / 180
+	cr, sr := math.Cos(r), math.Sin(r)
+	cp, sp := math.Cos(p), math.Sin(p)
+	cy, sy := math.Cos(y), math.Sin(y)
+	return [3][3]float64{
+		{cy * cp, cy*sp*sr - sy*cr, cy*sp*cr + sy*sr},
+		{sy * cp, sy*sp*sr + cy*cr, sy*sp*cr - cy*sr},
+		{-sp, cp * sr, cp * cr},
+	}
+}
+
+// legVectors returns the vector from every base joint to its platform joint.
+func (p *Platform) legVectors(pose Pose) [Legs]Vector {
+	rotation := RotationMatrix(pose[0], pose[1], pose[2])
+	translation := Vector{pose[3], pose[4], pose[5] + p.geometry.HomeHeight}
+
+	var legs [Legs]Vector
+	for i := 0; i < Legs; i++ {
+		joint := p.geometry.PlatformJoints[i]
+		for row := 0; row < 3; row++ {
+			legs[i][row] = translation[row] +
+				rotation[row][0]*joint[0] + rotation[row][1]*joint[1] + rotation[row][2]*joint[2] -
+				p.geometry.BaseJoints[i][row]
+		}
+	}
+	return legs
+}
+
+// LegLengths returns the joint to joint length of every leg for the pose.
+func (p *Platform) LegLengths(pose Pose) [Legs]float64 {
+	var lengths [Legs]float64
+	for i, leg := range p.legVectors(pose) {
+		lengths[i] = norm(leg)
+	}
+	return lengths
+}
+
+// CylinderLengths solves the inverse kinematics: it returns the cylinder
+// length of every leg for the pose.
+func (p *Platform) CylinderLengths(pose Pose) [Legs]float64 {
+	lengths := p.LegLengths(pose)
+	for i := range lengths {
+		lengths[i] = lengths[i] - p.geometry.LegLength + p.geometry.HomeStroke
+	}
+	return lengths
+}
+
+func norm(v Vector) float64 {
+	return math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+}
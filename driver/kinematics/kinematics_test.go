@@ -0,0 +1,108 @@
+package kinematics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPlatform(t *testing.T) {
+	_, err := NewPlatform(Geometry{})
+	assert.Error(t, err)
+
+	_, err = NewPlatform(Geometry{LegLength: 0.4, HomeStroke: 0.1569})
+	assert.Error(t, err)
+
+	geometry := testGeometry()
+	geometry.LegLength = 0.05
+	_, err = NewPlatform(geometry)
+	assert.Error(t, err)
+
+	platform, err := NewPlatform(testGeometry())
+	assert.NoError(t, err)
+	assert.Greater(t, platform.Geometry().HomeHeight, 0.0)
+}
+
+func TestCylinderLengthsHome(t *testing.T) {
+	platform, err := NewPlatform(testGeometry())
+	assert.NoError(t, err)
+
+	for _, length := range platform.CylinderLengths(Pose{}) {
+		assert.InDelta(t, 0.1569, length, 1e-12)
+	}
+}
+
+func TestCylinderLengthsHeave(t *testing.T) {
+	platform, err := NewPlatform(testGeometry())
+	assert.NoError(t, err)
+	geometry := platform.Geometry()
+
+	lengths := platform.CylinderLengths(Pose{0, 0, 0, 0, 0, 0.01})
+	for i, length := range lengths {
+		dx := geometry.PlatformJoints[i][0] - geometry.BaseJoints[i][0]
+		dy := geometry.PlatformJoints[i][1] - geometry.BaseJoints[i][1]
+		dz := geometry.HomeHeight + 0.01
+		expected := math.Sqrt(dx*dx+dy*dy+dz*dz) - geometry.LegLength + geometry.HomeStroke
+		assert.InDelta(t, expected, length, 1e-12)
+		assert.Greater(t, length, geometry.HomeStroke)
+	}
+}
+
+func TestCylinderLengthsYawSymmetry(t *testing.T) {
+	platform, err := NewPlatform(testGeometry())
+	assert.NoError(t, err)
+
+	// A pure yaw stretches one leg of every pair and shortens the other.
+	lengths := platform.CylinderLengths(Pose{0, 0, 5, 0, 0, 0})
+	for i := 0; i < Legs; i += 2 {
+		assert.InDelta(t, lengths[0], lengths[i], 1e-12)
+		assert.InDelta(t, lengths[1], lengths[i+1], 1e-12)
+	}
+	assert.Less(t, (lengths[0]-0.1569)*(lengths[1]-0.1569), 0.0)
+}
+
+func TestRotationMatrix(t *testing.T) {
+	rotation := RotationMatrix(0, 0, 90)
+	assert.InDelta(t, 0, rotation[0][0], 1e-12)
+	assert.InDelta(t, -1, rotation[0][1], 1e-12)
+	assert.InDelta(t, 1, rotation[1][0], 1e-12)
+	assert.InDelta(t, 1, rotation[2][2], 1e-12)
+}
+
+func TestLimitsCheck(t *testing.T) {
+	platform, err := NewPlatform(testGeometry())
+	assert.NoError(t, err)
+
+	var limits Limits
+	for i := 0; i < Legs; i++ {
+		limits.MinStroke[i] = 0.12
+		limits.MaxStroke[i] = 0.19
+	}
+	limits.MaxTilt = 5
+	limits.MaxTranslation = 0.01
+
+	pose := Pose{1, 1, 0, 0, 0, 0.005}
+	assert.Empty(t, limits.Check(pose, platform.CylinderLengths(pose)))
+
+	pose = Pose{6, 0, 0, 0, 0, 0}
+	assert.Len(t, limits.Check(pose, platform.CylinderLengths(pose)), 1)
+
+	pose = Pose{0, 0, 0, 0.01, 0.01, 0}
+	assert.Len(t, limits.Check(pose, platform.CylinderLengths(pose)), 1)
+
+	pose = Pose{0, 0, 0, 0, 0, 0.05}
+	assert.NotEmpty(t, limits.Check(pose, platform.CylinderLengths(pose)))
+
+	assert.Empty(t, Limits{}.Check(pose, platform.CylinderLengths(pose)))
+}
+
+// testGeometry is a symmetric platform for the tests; it is no real bow.
+func testGeometry() Geometry {
+	return Geometry{
+		BaseJoints:     HexagonJoints(0.25, 15),
+		PlatformJoints: HexagonJoints(0.15, 45),
+		LegLength:      0.4,
+		HomeStroke:     0.1569,
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kinematics
+
+import (
+	"fmt"
+	"math"
+)
+
+// Limits is the workspace of the platform. Zero values disable a limit.
+type Limits struct {
+	// MinStroke and MaxStroke bound the cylinder length of every leg, in meters.
+	MinStroke [Legs]float64
+	MaxStroke [Legs]float64
+	// MaxTilt bounds the angle between the platform normal and the base
+	// normal, in degrees.
+	MaxTilt float64
+	// MaxTranslation bounds the distance of the platform from home, in meters.
+	MaxTranslation float64
+}
+
+// Tilt returns the angle between the platform normal and the base normal of
+// the pose, in degrees.
+func Tilt(pose Pose) float64 {
+	rotation := RotationMatrix(pose[0], pose[1], pose[2])
+	return math.Acos(math.Max(-1, math.Min(1, rotation[2][2]))) * 180 / math.Pi
+}
+
+// Check returns the reasons the pose and its cylinder lengths break the
+// limits, or nil if they are within.
+func (l Limits) Check(pose Pose, cylinders [Legs]float64) []string {
+	var reasons []string
+	for i, length := range cylinders {
+		if l.MinStroke[i] == 0 && l.MaxStroke[i] == 0 {
+			continue
+		}
+		if length < l.MinStroke[i] || length > l.MaxStroke[i] {
+			reasons = append(reasons, fmt.Sprintf("cylinder %d length %.5f out of [%.5f, %.5f]",
+				i+1, length, l.MinStroke[i], l.MaxStroke[i]))
+		}
+	}
+	if l.MaxTilt > 0 {
+		if tilt := Tilt(pose); tilt > l.MaxTilt {
+			reasons = append(reasons, fmt.Sprintf("tilt %.3f exceeds %.3f", tilt, l.MaxTilt))
+		}
+	}
+	if l.MaxTranslation > 0 {
+		translation := norm(Vector{pose[3], pose[4], pose[5]})
+		if translation > l.MaxTranslation {
+			reasons = append(reasons, fmt.Sprintf("translation %.5f exceeds %.5f", translation, l.MaxTranslation))
+		}
+	}
+	return reasons
+}
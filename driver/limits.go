@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+)
+
+// FrameViolation lists why one frame is out of the platform limits.
+type FrameViolation struct {
+	Frame   int      `json:"frame"`
+	Reasons []string `json:"reasons"`
+}
+
+// LimitReport is the result of checking a segment against the platform limits.
+type LimitReport struct {
+	Segment    string           `json:"segment"`
+	Frames     int              `json:"frames"`
+	Violations []FrameViolation `json:"violations,omitempty"`
+}
+
+// FrameIndexes returns the indexes of the offending frames.
+func (r LimitReport) FrameIndexes() []int {
+	indexes := make([]int, 0, len(r.Violations))
+	for _, violation := range r.Violations {
+		indexes = append(indexes, violation.Frame)
+	}
+	return indexes
+}
+
+// CheckLimits solves every pose and checks it against the configured limits,
+// the soft limits of the cylinders and the range of the serial encoding.
+func (c *DigitalbowClient) CheckLimits(segment string, poses [][]float32) LimitReport {
+	calibration := c.Calibration()
+	report := LimitReport{Segment: segment, Frames: len(poses)}
+	clylen := make([]float32, kinematics.Legs)
+	for frame, movements := range poses {
+		c.Client.Solver.SoluteCylinderLength(movements, clylen)
+
+		var pose kinematics.Pose
+		var cylinders [kinematics.Legs]float64
+		for i := 0; i < kinematics.Legs; i++ {
+			pose[i] = float64(movements[i])
+			cylinders[i] = float64(clylen[i])
+		}
+		reasons := c.Client.Config.Limits.Check(pose, cylinders)
+		for i, length := range clylen {
+			if _, err := calibration[i].Count(float64(length)); errors.Is(err, ErrSoftLimit) {
+				reasons = append(reasons, fmt.Sprintf("cylinder %d length %.5f is beyond its soft limits", i+1, length))
+			} else if err != nil {
+				reasons = append(reasons, fmt.Sprintf("cylinder %d length %.5f can not be encoded", i+1, length))
+			}
+		}
+		if len(reasons) != 0 {
+			report.Violations = append(report.Violations, FrameViolation{Frame: frame, Reasons: reasons})
+		}
+	}
+	return report
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultFrequency is the playback rate in Hz of tracks without a frequency.
+const DefaultFrequency = 30
+
+// Playback sends frames at absolute deadlines derived from the track
+// frequency, so a slow frame delays only itself and never the whole run.
+type Playback struct {
+	// Period is the time between two frames.
+	Period time.Duration
+	// LateTolerance is how long after its deadline a frame may start before it
+	// is reported late.
+	LateTolerance time.Duration
+	// Control, when set, pauses, resumes and seeks the playback.
+	Control *PlaybackControl
+	// Transition, when set, is called before a seek jumps from the last frame
+	// sent, -1 if none was, to the frame sought, e.g. to move smoothly there.
+	Transition func(from, to int) error
+	// clock is the time of the playback, the wall clock when nil.
+	clock clock
+}
+
+// clock tells the time and wakes a playback up at its deadlines.
+type clock interface {
+	Now() time.Time
+	// After returns a channel receiving once d is over, and a function
+	// releasing it early.
+	After(d time.Duration) (<-chan time.Time, func())
+}
+
+// wallClock is the clock of the system.
+type wallClock struct{}
+
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+func (wallClock) After(d time.Duration) (<-chan time.Time, func()) {
+	timer := time.NewTimer(d)
+	return timer.C, func() { timer.Stop() }
+}
+
+// PlaybackControl pauses, resumes and seeks a running playback. It is safe
+// for concurrent use.
+type PlaybackControl struct {
+	mu     sync.Mutex
+	paused bool
+	// seek is the frame sought, -1 when none is.
+	seek    int
+	changed chan struct{}
+}
+
+// NewPlaybackControl returns the control of a playing playback.
+func NewPlaybackControl() *PlaybackControl {
+	return &PlaybackControl{seek: -1, changed: make(chan struct{}, 1)}
+}
+
+// Pause holds the playback at the last frame sent.
+func (c *PlaybackControl) Pause() {
+	c.set(func() { c.paused = true })
+}
+
+// Resume continues a paused playback from where it was held.
+func (c *PlaybackControl) Resume() {
+	c.set(func() { c.paused = false })
+}
+
+// Seek continues the playback at frame. A paused playback stays paused.
+func (c *PlaybackControl) Seek(frame int) {
+	c.set(func() { c.seek = frame })
+}
+
+// Paused reports whether the playback is paused.
+func (c *PlaybackControl) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+func (c *PlaybackControl) set(change func()) {
+	c.mu.Lock()
+	change()
+	c.mu.Unlock()
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the pause state and the frame sought, clearing the seek.
+func (c *PlaybackControl) take() (bool, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seek := c.seek
+	c.seek = -1
+	return c.paused, seek
+}
+
+// PlaybackReport is the timing of a finished playback.
+type PlaybackReport struct {
+	Frames      int           `json:"frames"`
+	Period      time.Duration `json:"period"`
+	Duration    time.Duration `json:"duration"`
+	LateFrames  []int         `json:"lateFrames,omitempty"`
+	MaxLateness time.Duration `json:"maxLateness"`
+	// MeanJitter and StdJitter are the mean and standard deviation of the
+	// difference between the deadline and the start of every frame.
+	MeanJitter time.Duration `json:"meanJitter"`
+	StdJitter  time.Duration `json:"stdJitter"`
+}
+
+func (r PlaybackReport) String() string {
+	return fmt.Sprintf("%d frames in %v (period %v), %d late, max lateness %v, jitter %v±%v",
+		r.Frames, r.Duration, r.Period, len(r.LateFrames), r.MaxLateness, r.MeanJitter, r.StdJitter)
+}
+
+// NewPlayback returns a playback for a track recorded at frequency Hz.
+func NewPlayback(frequency int) *Playback {
+	if frequency <= 0 {
+		frequency = DefaultFrequency
+	}
+	period := time.Second / time.Duration(frequency)
+	return &Playback{
+		Period:        period,
+		LateTolerance: period / 2,
+	}
+}
+
+// Scale stretches the playback period by factor, e.g. 2 plays twice as slow.
+func (p *Playback) Scale(factor float64) {
+	if factor <= 0 {
+		return
+	}
+	p.Period = time.Duration(float64(p.Period) * factor)
+	p.LateTolerance = time.Duration(float64(p.LateTolerance) * factor)
+}
+
+// Run calls send for every frame at its deadline and reports the timing. It
+// stops at the first error of send.
+func (p *Playback) Run(frames int, send func(frame int) error) (PlaybackReport, error) {
+	return p.RunContext(context.Background(), frames, send)
+}
+
+// RunContext is Run stopping with the error of ctx once it is done.
+func (p *Playback) RunContext(ctx context.Context, frames int, send func(frame int) error) (PlaybackReport, error) {
+	report := PlaybackReport{Period: p.Period}
+	var sum, sumSquares float64
+	var changed <-chan struct{}
+	if p.Control != nil {
+		changed = p.Control.changed
+	}
+
+	clock := p.clock
+	if clock == nil {
+		clock = wallClock{}
+	}
+	start := clock.Now()
+	finish := func() {
+		report.finish(clock.Now().Sub(start), sum, sumSquares)
+	}
+	// base is the deadline of frame zero, moved by pauses and seeks.
+	base := start
+	last := -1
+	for frame := 0; frame < frames; {
+		if p.Control != nil {
+			paused, seek := p.Control.take()
+			if seek >= 0 {
+				if p.Transition != nil {
+					if err := p.Transition(last, seek); err != nil {
+						finish()
+						return report, err
+					}
+				}
+				frame = seek
+				base = clock.Now().Add(-time.Duration(frame) * p.Period)
+				continue
+			}
+			if paused {
+				select {
+				case <-ctx.Done():
+					finish()
+					return report, ctx.Err()
+				case <-changed:
+				}
+				base = clock.Now().Add(-time.Duration(frame) * p.Period)
+				continue
+			}
+		}
+
+		deadline := base.Add(time.Duration(frame) * p.Period)
+		due, release := clock.After(deadline.Sub(clock.Now()))
+		select {
+		case <-ctx.Done():
+			release()
+			finish()
+			return report, ctx.Err()
+		case <-changed:
+			release()
+			continue
+		case <-due:
+		}
+
+		lateness := clock.Now().Sub(deadline)
+		if lateness > p.LateTolerance {
+			report.LateFrames = append(report.LateFrames, frame)
+		}
+		if lateness > report.MaxLateness {
+			report.MaxLateness = lateness
+		}
+		sum += float64(lateness)
+		sumSquares += float64(lateness) * float64(lateness)
+		report.Frames++
+
+		if err := send(frame); err != nil {
+			finish()
+			return report, err
+		}
+		last = frame
+		frame++
+	}
+	finish()
+	return report, nil
+}
+
+func (r *PlaybackReport) finish(duration time.Duration, sum, sumSquares float64) {
+	r.Duration = duration
+	if r.Frames == 0 {
+		return
+	}
+	mean := sum / float64(r.Frames)
+	r.MeanJitter = time.Duration(mean)
+	r.StdJitter = time.Duration(math.Sqrt(math.Max(0, sumSquares/float64(r.Frames)-mean*mean)))
+}
@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPlayback(t *testing.T) {
+	assert.Equal(t, time.Second/DefaultFrequency, NewPlayback(0).Period)
+	assert.Equal(t, 10*time.Millisecond, NewPlayback(100).Period)
+}
+
+// fakeClock is a clock which only moves when a playback waits on it or a
+// test sleeps.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) (<-chan time.Time, func()) {
+	c.Sleep(d)
+	due := make(chan time.Time, 1)
+	due <- c.now
+	return due, func() {}
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+}
+
+func TestPlaybackRunDoesNotDrift(t *testing.T) {
+	playback := NewPlayback(200)
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	playback.clock = clock
+	start := clock.Now()
+
+	var sent []time.Duration
+	report, err := playback.Run(20, func(frame int) error {
+		sent = append(sent, clock.Now().Sub(start))
+		// A slow frame must not delay the following deadlines.
+		if frame == 5 {
+			clock.Sleep(12 * time.Millisecond)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, sent, 20)
+	assert.Equal(t, 20, report.Frames)
+	assert.Equal(t, []int{6}, report.LateFrames)
+	assert.Equal(t, 7*time.Millisecond, report.MaxLateness)
+	for frame, at := range sent {
+		switch frame {
+		case 6, 7:
+			assert.Equal(t, 37*time.Millisecond, at, "frame %d", frame)
+		default:
+			assert.Equal(t, time.Duration(frame)*5*time.Millisecond, at, "frame %d", frame)
+		}
+	}
+	assert.Equal(t, 95*time.Millisecond, report.Duration)
+}
+
+func TestPlaybackRunStopsOnError(t *testing.T) {
+	report, err := NewPlayback(1000).Run(10, func(frame int) error {
+		if frame == 3 {
+			return errors.New("write failed")
+		}
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 4, report.Frames)
+}
+
+func TestPlaybackRunContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	report, err := NewPlayback(10).RunContext(ctx, 100, func(frame int) error {
+		if frame == 1 {
+			cancel()
+		}
+		return nil
+	})
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 2, report.Frames)
+	// The cancellation does not wait for the next deadline.
+	assert.Less(t, int64(time.Since(start)), int64(150*time.Millisecond))
+}
+
+func TestPlaybackControl(t *testing.T) {
+	playback := NewPlayback(200)
+	playback.LateTolerance = 20 * time.Millisecond
+	control := NewPlaybackControl()
+	playback.Control = control
+	var transitions [][2]int
+	playback.Transition = func(from, to int) error {
+		transitions = append(transitions, [2]int{from, to})
+		return nil
+	}
+
+	var sent []int
+	var pausedAt time.Time
+	report, err := playback.Run(10, func(frame int) error {
+		sent = append(sent, frame)
+		switch {
+		case frame == 2 && pausedAt.IsZero():
+			control.Pause()
+			pausedAt = time.Now()
+			go func() {
+				time.Sleep(30 * time.Millisecond)
+				control.Seek(7)
+				control.Resume()
+			}()
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 7, 8, 9}, sent)
+	assert.Equal(t, [][2]int{{2, 7}}, transitions)
+	assert.Equal(t, 6, report.Frames)
+	// The pause holds the playback without making the next frames late.
+	assert.GreaterOrEqual(t, int64(report.Duration), int64(30*time.Millisecond))
+	assert.Empty(t, report.LateFrames)
+	assert.False(t, control.Paused())
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"math"
+)
+
+// Motion profiling modes.
+const (
+	// ProfileClamp makes every axis follow the poses within the limits,
+	// braking early enough not to overshoot, so spikes are flattened.
+	ProfileClamp = "clamp"
+	// ProfileTimeScale keeps the path and slows the playback down until the
+	// limits hold.
+	ProfileTimeScale = "timescale"
+)
+
+// MotionLimits are the per axis limits of the commanded trajectory, in the
+// pose layout roll, pitch, yaw (degrees) and x, y, z (meters), per second,
+// per second squared and per second cubed. Zero disables a limit.
+type MotionLimits struct {
+	Mode         string
+	Velocity     [6]float64
+	Acceleration [6]float64
+	Jerk         [6]float64
+}
+
+// Enabled reports whether any limit is set.
+func (l MotionLimits) Enabled() bool {
+	for i := 0; i < 6; i++ {
+		if l.Velocity[i] > 0 || l.Acceleration[i] > 0 || l.Jerk[i] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileReport tells how much the profiling modified a trajectory.
+type ProfileReport struct {
+	Mode           string     `json:"mode"`
+	Frames         int        `json:"frames"`
+	ModifiedFrames int        `json:"modifiedFrames"`
+	MaxDeviation   [6]float64 `json:"maxDeviation"`
+	// TimeScale is how many times slower the trajectory has to be played.
+	TimeScale float64 `json:"timeScale"`
+}
+
+func (r ProfileReport) String() string {
+	return fmt.Sprintf("%s: %d of %d frames modified, max deviation %v, time scale %.3f",
+		r.Mode, r.ModifiedFrames, r.Frames, r.MaxDeviation, r.TimeScale)
+}
+
+// ProfileMotion enforces the limits on poses played at frequency Hz. In clamp
+// mode it returns new poses; in time scale mode it returns the poses unchanged
+// and the report says how much the playback period has to be stretched.
+func ProfileMotion(poses [][]float32, frequency int, limits MotionLimits) ([][]float32, ProfileReport, error) {
+	if frequency <= 0 {
+		frequency = DefaultFrequency
+	}
+	mode := limits.Mode
+	if mode == "" {
+		mode = ProfileClamp
+	}
+	report := ProfileReport{Mode: mode, Frames: len(poses), TimeScale: 1}
+	if !limits.Enabled() || len(poses) < 2 {
+		return poses, report, nil
+	}
+
+	dt := 1 / float64(frequency)
+	switch mode {
+	case ProfileClamp:
+		profiled := clampMotion(poses, dt, limits)
+		return profiled, report.withDeviation(poses, profiled), nil
+	case ProfileTimeScale:
+		report.TimeScale = timeScale(poses, dt, limits)
+		return poses, report, nil
+	default:
+		return poses, report, fmt.Errorf("unknown motion profile mode %s", mode)
+	}
+}
+
+// clampMotion makes every axis follow its poses as closely as the jerk,
+// acceleration and velocity limits allow. An axis only moves toward its target
+// as fast as it can still brake without passing it, so a step is reached
+// without overshoot.
+func clampMotion(poses [][]float32, dt float64, limits MotionLimits) [][]float32 {
+	profiled := make([][]float32, len(poses))
+	for i := range poses {
+		profiled[i] = append([]float32(nil), poses[i]...)
+	}
+	for axis := 0; axis < 6 && axis < len(poses[0]); axis++ {
+		l := axisLimits{
+			velocity:     limitOrInf(limits.Velocity[axis]),
+			acceleration: limitOrInf(limits.Acceleration[axis]),
+			jerk:         limitOrInf(limits.Jerk[axis]),
+			dt:           dt,
+		}
+		if math.IsInf(l.velocity, 1) && math.IsInf(l.acceleration, 1) && math.IsInf(l.jerk, 1) {
+			continue
+		}
+		state := axisState{p: float64(poses[0][axis])}
+		for i := 1; i < len(poses); i++ {
+			state = l.step(state, l.track(state, float64(poses[i][axis])))
+			profiled[i][axis] = float32(state.p)
+		}
+	}
+	return profiled
+}
+
+// limitOrInf returns limit, or +Inf when the limit is disabled.
+func limitOrInf(limit float64) float64 {
+	if limit > 0 {
+		return limit
+	}
+	return math.Inf(1)
+}
+
+// axisLimits are the limits of one axis played with period dt, +Inf when
+// disabled.
+type axisLimits struct {
+	velocity, acceleration, jerk float64
+	dt                           float64
+}
+
+// axisState is the position, velocity and acceleration of an axis.
+type axisState struct {
+	p, v, a float64
+}
+
+// Bounds of the braking simulation.
+const (
+	brakeSteps     = 10000
+	brakeTolerance = 1e-9
+	bisections     = 40
+)
+
+// step applies the acceleration a for one period, the velocity clamped to its
+// limit.
+func (l axisLimits) step(s axisState, a float64) axisState {
+	v := clamp(s.v+a*l.dt, -l.velocity, l.velocity)
+	return axisState{p: s.p + v*l.dt, v: v, a: (v - s.v) / l.dt}
+}
+
+// window returns the accelerations reachable within one period from a.
+func (l axisLimits) window(a float64) (float64, float64) {
+	low, high := -l.acceleration, l.acceleration
+	if !math.IsInf(l.jerk, 1) {
+		low, high = math.Max(low, a-l.jerk*l.dt), math.Min(high, a+l.jerk*l.dt)
+	}
+	return low, high
+}
+
+// release returns the velocity gained while the acceleration a is brought
+// back to zero at the jerk limit, after the period it is applied.
+func (l axisLimits) release(a float64) float64 {
+	if math.IsInf(l.jerk, 1) {
+		return 0
+	}
+	step := l.jerk * l.dt
+	k := math.Floor(math.Abs(a) / step)
+	return math.Copysign(l.dt*(k*math.Abs(a)-step*k*(k+1)/2), a)
+}
+
+// settle returns the acceleration of the window whose velocity, once the
+// acceleration is released, is the closest to velocity.
+func (l axisLimits) settle(s axisState, velocity float64) float64 {
+	low, high := l.window(s.a)
+	final := func(a float64) float64 { return s.v + a*l.dt + l.release(a) }
+	switch {
+	case math.IsInf(low, -1) && math.IsInf(high, 1):
+		return (velocity - s.v) / l.dt
+	case math.IsInf(low, -1):
+		low = math.Min(high, (velocity-s.v)/l.dt)
+	case math.IsInf(high, 1):
+		high = math.Max(low, (velocity-s.v)/l.dt)
+	}
+	if final(low) >= velocity {
+		return low
+	}
+	if final(high) <= velocity {
+		return high
+	}
+	for i := 0; i < bisections; i++ {
+		middle := (low + high) / 2
+		if final(middle) < velocity {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	return (low + high) / 2
+}
+
+// safe reports whether braking from s stops the axis without passing target
+// in the direction sign.
+func (l axisLimits) safe(s axisState, target, sign float64) bool {
+	for i := 0; i < brakeSteps; i++ {
+		if sign*(s.p-target) > brakeTolerance {
+			return false
+		}
+		if math.Abs(s.v) < brakeTolerance && math.Abs(s.a) < brakeTolerance {
+			return true
+		}
+		s = l.step(s, l.settle(s, 0))
+	}
+	return true
+}
+
+// track returns the acceleration moving the axis toward target as fast as it
+// can still brake without passing it.
+func (l axisLimits) track(s axisState, target float64) float64 {
+	sign := math.Copysign(1, target-s.p)
+	if target == s.p {
+		sign = -math.Copysign(1, s.v)
+	}
+	brake := l.settle(s, 0)
+	// The fastest move toward target, and the one landing on it: anything
+	// faster passes it at once.
+	fastest := l.settle(s, sign*l.velocity)
+	if math.IsInf(l.velocity, 1) {
+		low, high := l.window(s.a)
+		fastest = high
+		if sign < 0 {
+			fastest = low
+		}
+	}
+	landing := ((target-s.p)/l.dt - s.v) / l.dt
+	if sign*landing < sign*fastest {
+		low, high := l.window(s.a)
+		fastest = clamp(landing, low, high)
+	}
+	if sign*fastest <= sign*brake || l.safe(l.step(s, fastest), target, sign) {
+		return fastest
+	}
+	if !l.safe(l.step(s, brake), target, sign) {
+		return brake
+	}
+	safe, unsafe := brake, fastest
+	for i := 0; i < bisections; i++ {
+		middle := (safe + unsafe) / 2
+		if l.safe(l.step(s, middle), target, sign) {
+			safe = middle
+		} else {
+			unsafe = middle
+		}
+	}
+	return safe
+}
+
+// withDeviation fills the deviation of the profiled trajectory in the report.
+func (r ProfileReport) withDeviation(poses, profiled [][]float32) ProfileReport {
+	for i := range poses {
+		modified := false
+		for axis := range poses[i] {
+			deviation := math.Abs(float64(profiled[i][axis] - poses[i][axis]))
+			if deviation > 1e-6 {
+				modified = true
+			}
+			r.MaxDeviation[axis] = math.Max(r.MaxDeviation[axis], deviation)
+		}
+		if modified {
+			r.ModifiedFrames++
+		}
+	}
+	return r
+}
+
+// timeScale returns the smallest factor the playback period has to be
+// multiplied by to keep the finite difference derivatives within the limits.
+// Velocity scales with 1/s, acceleration with 1/s² and jerk with 1/s³.
+func timeScale(poses [][]float32, dt float64, limits MotionLimits) float64 {
+	scale := 1.0
+	for axis := 0; axis < 6; axis++ {
+		var velocity, acceleration, jerk float64
+		for i := 1; i < len(poses); i++ {
+			v := derivative(poses, i, axis, 1, dt)
+			velocity = math.Max(velocity, math.Abs(v))
+			if i >= 2 {
+				acceleration = math.Max(acceleration, math.Abs(derivative(poses, i, axis, 2, dt)))
+			}
+			if i >= 3 {
+				jerk = math.Max(jerk, math.Abs(derivative(poses, i, axis, 3, dt)))
+			}
+		}
+		if limits.Velocity[axis] > 0 {
+			scale = math.Max(scale, velocity/limits.Velocity[axis])
+		}
+		if limits.Acceleration[axis] > 0 {
+			scale = math.Max(scale, math.Sqrt(acceleration/limits.Acceleration[axis]))
+		}
+		if limits.Jerk[axis] > 0 {
+			scale = math.Max(scale, math.Cbrt(jerk/limits.Jerk[axis]))
+		}
+	}
+	return scale
+}
+
+// derivative returns the backward finite difference of the given order at
+// frame i.
+func derivative(poses [][]float32, i, axis, order int, dt float64) float64 {
+	if order == 0 {
+		return float64(poses[i][axis])
+	}
+	return (derivative(poses, i, axis, order-1, dt) - derivative(poses, i-1, axis, order-1, dt)) / dt
+}
+
+func clamp(value, low, high float64) float64 {
+	return math.Max(low, math.Min(high, value))
+}
@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func spikeTrack() [][]float32 {
+	poses := make([][]float32, 30)
+	for i := range poses {
+		poses[i] = make([]float32, 6)
+	}
+	// One frame of tracking noise on roll and z.
+	poses[10][0] = 5
+	poses[10][5] = 0.01
+	return poses
+}
+
+func TestProfileMotionDisabled(t *testing.T) {
+	poses := spikeTrack()
+	profiled, report, err := ProfileMotion(poses, 30, MotionLimits{})
+	assert.NoError(t, err)
+	assert.Equal(t, poses, profiled)
+	assert.Equal(t, 0, report.ModifiedFrames)
+}
+
+func TestProfileMotionClamp(t *testing.T) {
+	limits := MotionLimits{
+		Velocity:     [6]float64{30, 30, 30, 0.05, 0.05, 0.05},
+		Acceleration: [6]float64{300, 300, 300, 0.5, 0.5, 0.5},
+	}
+	profiled, report, err := ProfileMotion(spikeTrack(), 30, limits)
+	assert.NoError(t, err)
+	assert.Equal(t, ProfileClamp, report.Mode)
+	assert.Greater(t, report.ModifiedFrames, 0)
+
+	dt := 1.0 / 30
+	for i := 1; i < len(profiled); i++ {
+		for axis := 0; axis < 6; axis++ {
+			velocity := float64(profiled[i][axis]-profiled[i-1][axis]) / dt
+			assert.LessOrEqual(t, velocity, limits.Velocity[axis]*1.0001)
+			assert.GreaterOrEqual(t, velocity, -limits.Velocity[axis]*1.0001)
+		}
+	}
+	assert.Greater(t, report.MaxDeviation[0], 3.9)
+	assert.Less(t, profiled[10][0], float32(1.01))
+}
+
+func TestProfileMotionTimeScale(t *testing.T) {
+	poses := spikeTrack()
+	limits := MotionLimits{Mode: ProfileTimeScale, Velocity: [6]float64{30}}
+	profiled, report, err := ProfileMotion(poses, 30, limits)
+	assert.NoError(t, err)
+	assert.Equal(t, poses, profiled)
+	// 5 degrees in one 30 Hz frame is 150 degrees per second.
+	assert.InDelta(t, 5, report.TimeScale, 1e-6)
+
+	_, _, err = ProfileMotion(poses, 30, MotionLimits{Mode: "unknown", Velocity: [6]float64{1}})
+	assert.Error(t, err)
+}
+
+func TestProfileMotionClampStep(t *testing.T) {
+	cases := map[string]MotionLimits{
+		"acceleration":    {Acceleration: [6]float64{100}},
+		"jerk":            {Jerk: [6]float64{3000}},
+		"velocity":        {Velocity: [6]float64{30}},
+		"deploy":          {Velocity: [6]float64{60}, Acceleration: [6]float64{600}},
+		"all":             {Velocity: [6]float64{60}, Acceleration: [6]float64{600}, Jerk: [6]float64{6000}},
+		"slow s-curve":    {Acceleration: [6]float64{100}, Jerk: [6]float64{500}},
+		"down, all three": {Velocity: [6]float64{20}, Acceleration: [6]float64{100}, Jerk: [6]float64{1000}},
+	}
+	const frequency = 30
+	dt := 1.0 / frequency
+	for name, limits := range cases {
+		t.Run(name, func(t *testing.T) {
+			step := float32(5)
+			if name == "down, all three" {
+				step = -5
+			}
+			poses := make([][]float32, 3*frequency)
+			for i := range poses {
+				poses[i] = make([]float32, 6)
+				if i > 0 {
+					poses[i][0] = step
+				}
+			}
+			profiled, _, err := ProfileMotion(poses, frequency, limits)
+			assert.NoError(t, err)
+
+			var velocity, acceleration float64
+			for i := 1; i < len(profiled); i++ {
+				moved := profiled[i][0] - profiled[i-1][0]
+				// No overshoot: the axis only moves toward the step and stays
+				// within it.
+				assert.GreaterOrEqual(t, moved*step, float32(-1e-6), "frame %d", i)
+				assert.LessOrEqual(t, profiled[i][0]*step, step*step+1e-4, "frame %d", i)
+
+				v := float64(moved) / dt
+				a := (v - velocity) / dt
+				if limits.Velocity[0] > 0 {
+					assert.LessOrEqual(t, math.Abs(v), limits.Velocity[0]*1.001, "frame %d", i)
+				}
+				if limits.Acceleration[0] > 0 {
+					assert.LessOrEqual(t, math.Abs(a), limits.Acceleration[0]*1.001, "frame %d", i)
+				}
+				if limits.Jerk[0] > 0 && limits.Velocity[0] == 0 {
+					assert.LessOrEqual(t, math.Abs(a-acceleration)/dt, limits.Jerk[0]*1.001, "frame %d", i)
+				}
+				velocity, acceleration = v, a
+			}
+			// Converged well before the end of the track.
+			for _, pose := range profiled[2*frequency:] {
+				assert.InDelta(t, step, pose[0], 1e-4)
+			}
+		})
+	}
+}
+
+func TestProfileMotionClampFollowsSlowMotion(t *testing.T) {
+	limits := MotionLimits{Velocity: [6]float64{60}, Acceleration: [6]float64{600}, Jerk: [6]float64{6000}}
+	poses := make([][]float32, 300)
+	for i := range poses {
+		poses[i] = make([]float32, 6)
+		poses[i][0] = float32(5 * math.Sin(float64(i)/30))
+	}
+	profiled, report, err := ProfileMotion(poses, 30, limits)
+	assert.NoError(t, err)
+	assert.Less(t, report.MaxDeviation[0], 0.05)
+	assert.InDelta(t, poses[len(poses)-1][0], profiled[len(poses)-1][0], 0.05)
+}
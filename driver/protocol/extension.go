@@ -0,0 +1,154 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import "fmt"
+
+// The protocol extensions are commands beyond the controller protocol: a
+// handshake, homing, stop and the feedback frames. The simulator implements
+// them, but no bow controller is known to answer them yet, so they are kept
+// out of the controller commands and ParseMessage, and the driver only sends
+// or expects them when its protocolExtensions are enabled.
+const (
+	// CommandHandshake asks for the controller version; the controller answers
+	// with the same command and a Version payload.
+	CommandHandshake Command = 0xF0
+	// CommandHome drives every cylinder to its home position.
+	CommandHome Command = 0xF1
+	// CommandStop halts the cylinders where they are.
+	CommandStop Command = 0xF2
+	// CommandPositions reports the six measured cylinder positions.
+	CommandPositions Command = 0xF4
+	// CommandStatus reports the controller state and fault code.
+	CommandStatus Command = 0xF5
+	// CommandAck acknowledges a command with a result code.
+	CommandAck Command = 0xF6
+)
+
+var extensionNames = map[Command]string{
+	CommandHandshake: "handshake",
+	CommandHome:      "home",
+	CommandStop:      "stop",
+	CommandPositions: "positions",
+	CommandStatus:    "status",
+	CommandAck:       "ack",
+}
+
+// Extension reports whether the command is a protocol extension rather than
+// a command of the controller protocol.
+func (c Command) Extension() bool {
+	_, ok := commandNames[c]
+	return !ok
+}
+
+// Status is the payload of a status frame.
+type Status struct {
+	State byte
+	// Fault is zero when the controller has no fault.
+	Fault byte
+}
+
+// Controller states reported in Status.State.
+const (
+	StateIdle    byte = 0
+	StateMoving  byte = 1
+	StateHoming  byte = 2
+	StateStopped byte = 3
+	StateFault   byte = 4
+)
+
+// Payload returns the payload of a status frame.
+func (s Status) Payload() []byte {
+	return []byte{s.State, s.Fault}
+}
+
+// ParseStatus decodes the payload of a status frame.
+func ParseStatus(payload []byte) (Status, error) {
+	if len(payload) != 2 {
+		return Status{}, fmt.Errorf("%w: status payload of %d bytes", ErrPayload, len(payload))
+	}
+	return Status{State: payload[0], Fault: payload[1]}, nil
+}
+
+// Version is the payload of a handshake reply.
+type Version struct {
+	Major, Minor byte
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Payload returns the payload of a handshake reply.
+func (v Version) Payload() []byte {
+	return []byte{v.Major, v.Minor}
+}
+
+// ParseVersion decodes the payload of a handshake reply.
+func ParseVersion(payload []byte) (Version, error) {
+	if len(payload) != 2 {
+		return Version{}, fmt.Errorf("%w: version payload of %d bytes", ErrPayload, len(payload))
+	}
+	return Version{Major: payload[0], Minor: payload[1]}, nil
+}
+
+// Ack is the payload of an acknowledgement.
+type Ack struct {
+	Command Command
+	// Code is zero when the command was accepted.
+	Code byte
+}
+
+// Payload returns the payload of an acknowledgement.
+func (a Ack) Payload() []byte {
+	return []byte{byte(a.Command), a.Code}
+}
+
+// ParseAck decodes the payload of an acknowledgement.
+func ParseAck(payload []byte) (Ack, error) {
+	if len(payload) != 2 {
+		return Ack{}, fmt.Errorf("%w: ack payload of %d bytes", ErrPayload, len(payload))
+	}
+	return Ack{Command: Command(payload[0]), Code: payload[1]}, nil
+}
+
+// ParseExtension decodes the payload of a protocol extension frame according
+// to its command: Positions, Status, Version, Ack, or nil for the commands
+// without payload. The handshake request has no payload and its reply a
+// Version.
+func ParseExtension(frame Frame) (Message, error) {
+	switch frame.Command {
+	case CommandPositions:
+		return ParsePositions(frame.Payload)
+	case CommandStatus:
+		return ParseStatus(frame.Payload)
+	case CommandAck:
+		return ParseAck(frame.Payload)
+	case CommandHandshake:
+		if len(frame.Payload) == 0 {
+			return nil, nil
+		}
+		return ParseVersion(frame.Payload)
+	case CommandHome, CommandStop:
+		if len(frame.Payload) != 0 {
+			return nil, fmt.Errorf("%w: %v frame with %d payload bytes", ErrPayload, frame.Command, len(frame.Payload))
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: 0x%02X is not a protocol extension", ErrCommand, byte(frame.Command))
+	}
+}
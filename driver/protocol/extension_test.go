@@ -0,0 +1,68 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// extensionFrames are the frames of the protocol extensions as the simulator
+// sends or expects them. They are no controller protocol, see extension.go.
+var extensionFrames = []goldenFrame{
+	{
+		name:    "positions report",
+		hex:     "55aa1301f4010000020000030000040000050000060000" + "1d",
+		frame:   Frame{Address: 1, Command: CommandPositions, Payload: Positions{}.Payload()},
+		message: Positions{},
+	},
+	{
+		name:  "handshake request",
+		hex:   "55aa01fff0f0",
+		frame: Frame{Address: BroadcastAddress, Command: CommandHandshake},
+	},
+	{
+		name:    "handshake reply",
+		hex:     "55aa0301f00102f7",
+		frame:   Frame{Address: 1, Command: CommandHandshake, Payload: Version{1, 2}.Payload()},
+		message: Version{1, 2},
+	},
+	{
+		name:  "home",
+		hex:   "55aa01fff1f1",
+		frame: Frame{Address: BroadcastAddress, Command: CommandHome},
+	},
+	{
+		name:  "stop",
+		hex:   "55aa01fff2f2",
+		frame: Frame{Address: BroadcastAddress, Command: CommandStop},
+	},
+	{
+		name:    "status fault",
+		hex:     "55aa0301f5040704",
+		frame:   Frame{Address: 1, Command: CommandStatus, Payload: Status{State: StateFault, Fault: 7}.Payload()},
+		message: Status{State: StateFault, Fault: 7},
+	},
+	{
+		name:    "ack set positions",
+		hex:     "55aa0301f6f300" + "ed",
+		frame:   Frame{Address: 1, Command: CommandAck, Payload: Ack{Command: CommandSetPositions}.Payload()},
+		message: Ack{Command: CommandSetPositions},
+	},
+}
+
+func TestExtensionFrames(t *testing.T) {
+	testGoldenFrames(t, extensionFrames, ParseExtension)
+
+	_, err := ParseExtension(goldenFrames[0].frame)
+	assert.True(t, errors.Is(err, ErrCommand))
+	_, err = ParseExtension(Frame{Command: CommandHome, Payload: []byte{1}})
+	assert.True(t, errors.Is(err, ErrPayload))
+}
+
+func TestExtension(t *testing.T) {
+	assert.False(t, CommandSetPositions.Extension())
+	for _, command := range []Command{CommandHandshake, CommandHome, CommandStop, CommandPositions, CommandStatus, CommandAck} {
+		assert.True(t, command.Extension(), "%v", command)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Cylinders is the number of cylinders of a positions frame.
+const Cylinders = 6
+
+// Cylinder length in meters at encoder count zero, and encoder counts per meter.
+const (
+	StrokeZero  = 0.1569
+	StrokeScale = 40000
+)
+
+// positionSize is the payload size of one cylinder: its id from 1 and its
+// little endian int16 encoder count.
+const positionSize = 3
+
+// Positions is the encoder count of every cylinder.
+type Positions [Cylinders]int16
+
+// Count converts a cylinder length in meters into the nearest encoder count.
+// Truncating instead puts about half the lengths that are a whole number of
+// counts, such as 0.1669 m, one count low through rounding errors. It refuses
+// lengths whose count does not fit an int16.
+func Count(length float64) (int16, error) {
+	count := math.Round((length - StrokeZero) * StrokeScale)
+	if math.IsNaN(count) || count < math.MinInt16 || count > math.MaxInt16 {
+		return 0, fmt.Errorf("%w: cylinder length %v is %v counts", ErrOverflow, length, count)
+	}
+	return int16(count), nil
+}
+
+// Length converts encoder counts into a cylinder length in meters.
+func Length(count int16) float64 {
+	return StrokeZero + float64(count)/StrokeScale
+}
+
+// PositionsFromLengths converts cylinder lengths in meters into counts.
+func PositionsFromLengths(lengths []float32) (Positions, error) {
+	var positions Positions
+	if len(lengths) != Cylinders {
+		return positions, fmt.Errorf("%w: %d cylinder lengths, expect %d", ErrPayload, len(lengths), Cylinders)
+	}
+	for i, length := range lengths {
+		count, err := Count(float64(length))
+		if err != nil {
+			return positions, fmt.Errorf("cylinder %d: %w", i+1, err)
+		}
+		positions[i] = count
+	}
+	return positions, nil
+}
+
+// Lengths converts the counts into cylinder lengths in meters.
+func (p Positions) Lengths() []float32 {
+	lengths := make([]float32, Cylinders)
+	for i, count := range p {
+		lengths[i] = float32(Length(count))
+	}
+	return lengths
+}
+
+// Payload returns the payload of a positions frame.
+func (p Positions) Payload() []byte {
+	payload := make([]byte, 0, Cylinders*positionSize)
+	for i, count := range p {
+		payload = append(payload, byte(i+1), 0, 0)
+		binary.LittleEndian.PutUint16(payload[len(payload)-2:], uint16(count))
+	}
+	return payload
+}
+
+// ParsePositions decodes the payload of a set-positions or positions frame.
+func ParsePositions(payload []byte) (Positions, error) {
+	var positions Positions
+	if len(payload) != Cylinders*positionSize {
+		return positions, fmt.Errorf("%w: positions payload of %d bytes", ErrPayload, len(payload))
+	}
+	for i := range positions {
+		item := payload[i*positionSize : (i+1)*positionSize]
+		if int(item[0]) != i+1 {
+			return positions, fmt.Errorf("%w: cylinder id %d at position %d", ErrPayload, item[0], i+1)
+		}
+		positions[i] = int16(binary.LittleEndian.Uint16(item[1:]))
+	}
+	return positions, nil
+}
+
+// SetPositionsFrame returns the frame commanding the cylinder positions.
+func SetPositionsFrame(address byte, positions Positions) Frame {
+	return Frame{Address: address, Command: CommandSetPositions, Payload: positions.Payload()}
+}
+
+// Message is the decoded content of a frame, Positions for the controller
+// protocol.
+type Message interface{}
+
+// ParseMessage decodes the payload of a controller protocol frame according to
+// its command. It refuses the extensions, see ParseExtension.
+func ParseMessage(frame Frame) (Message, error) {
+	switch frame.Command {
+	case CommandSetPositions:
+		return ParsePositions(frame.Payload)
+	default:
+		if _, ok := extensionNames[frame.Command]; ok {
+			return nil, fmt.Errorf("%w: %v is a protocol extension", ErrCommand, frame.Command)
+		}
+		return nil, fmt.Errorf("%w: 0x%02X", ErrCommand, byte(frame.Command))
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package protocol encodes and decodes the serial frames of the bow
+// controller. A frame is
+//
+//	0x55 0xAA length address command payload... checksum
+//
+// where length counts the command and payload bytes and checksum is the low
+// byte of the sum of length, address, command and payload.
+package protocol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Frame header bytes.
+const (
+	Header0 = 0x55
+	Header1 = 0xAA
+)
+
+// BroadcastAddress addresses every controller on the bus.
+const BroadcastAddress = 0xFF
+
+// MaxPayload is the largest payload a one byte length can describe.
+const MaxPayload = 0xFF - 1
+
+// overhead is the number of frame bytes besides the payload.
+const overhead = 6
+
+// Command is the command byte of a frame.
+type Command byte
+
+// Commands of the bow controller protocol. The simulator understands more,
+// see extension.go.
+const (
+	// CommandSetPositions commands the six cylinder positions.
+	CommandSetPositions Command = 0xF3
+)
+
+var commandNames = map[Command]string{
+	CommandSetPositions: "set-positions",
+}
+
+func (c Command) String() string {
+	if name, ok := commandNames[c]; ok {
+		return name
+	}
+	if name, ok := extensionNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("command(0x%02X)", byte(c))
+}
+
+// Errors returned by the codec.
+var (
+	// ErrIncomplete means more bytes are needed to decode a frame.
+	ErrIncomplete = errors.New("incomplete frame")
+	ErrHeader     = errors.New("bad frame header")
+	ErrChecksum   = errors.New("bad frame checksum")
+	ErrLength     = errors.New("bad frame length")
+	ErrCommand    = errors.New("unknown frame command")
+	ErrPayload    = errors.New("bad frame payload")
+	ErrOverflow   = errors.New("value overflows the frame field")
+)
+
+// Frame is one protocol frame.
+type Frame struct {
+	Address byte
+	Command Command
+	Payload []byte
+}
+
+// Encode returns the bytes of the frame.
+func (f Frame) Encode() ([]byte, error) {
+	if len(f.Payload) > MaxPayload {
+		return nil, fmt.Errorf("%w: payload of %d bytes", ErrOverflow, len(f.Payload))
+	}
+	length := byte(len(f.Payload) + 1)
+	b := make([]byte, 0, len(f.Payload)+overhead)
+	b = append(b, Header0, Header1, length, f.Address, byte(f.Command))
+	b = append(b, f.Payload...)
+	return append(b, checksum(b[2:])), nil
+}
+
+// Decode decodes the frame at the start of data and returns the number of
+// bytes it used. It returns ErrIncomplete when data ends inside the frame.
+func Decode(data []byte) (Frame, int, error) {
+	var frame Frame
+	if len(data) < 2 {
+		return frame, 0, ErrIncomplete
+	}
+	if data[0] != Header0 || data[1] != Header1 {
+		return frame, 0, ErrHeader
+	}
+	if len(data) < 3 {
+		return frame, 0, ErrIncomplete
+	}
+	if data[2] == 0 {
+		return frame, 0, ErrLength
+	}
+	size := int(data[2]) + overhead - 1
+	if len(data) < size {
+		return frame, 0, ErrIncomplete
+	}
+	if sum := checksum(data[2 : size-1]); sum != data[size-1] {
+		return frame, size, fmt.Errorf("%w: 0x%02X, expect 0x%02X", ErrChecksum, data[size-1], sum)
+	}
+	frame.Address = data[3]
+	frame.Command = Command(data[4])
+	frame.Payload = append([]byte(nil), data[5:size-1]...)
+	return frame, size, nil
+}
+
+func checksum(b []byte) byte {
+	var sum byte
+	for _, v := range b {
+		sum += v
+	}
+	return sum
+}
+
+// Decoder reads frames from a byte stream, skipping bytes until a header.
+type Decoder struct {
+	r *bufio.Reader
+	// Skipped counts the bytes discarded while looking for a header.
+	Skipped int
+}
+
+// NewDecoder returns a decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// ReadFrame returns the next frame. A frame with a bad checksum is consumed
+// and reported with ErrChecksum; reading can go on after it.
+func (d *Decoder) ReadFrame() (Frame, error) {
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return Frame{}, err
+		}
+		if b != Header0 {
+			d.Skipped++
+			continue
+		}
+		next, err := d.r.Peek(2)
+		if err != nil {
+			return Frame{}, err
+		}
+		if next[0] != Header1 || next[1] == 0 {
+			d.Skipped++
+			continue
+		}
+		data := make([]byte, int(next[1])+overhead-1)
+		data[0] = b
+		if _, err = io.ReadFull(d.r, data[1:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return Frame{}, err
+		}
+		frame, _, err := Decode(data)
+		return frame, err
+	}
+}
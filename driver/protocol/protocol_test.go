@@ -0,0 +1,167 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goldenFrame is a frame, its bytes and its decoded payload.
+type goldenFrame struct {
+	name    string
+	hex     string
+	frame   Frame
+	message Message
+}
+
+// goldenFrames are frames of the controller protocol as the controller
+// expects them. Do not edit the bytes to make a test pass: a change here is a
+// protocol change.
+var goldenFrames = []goldenFrame{
+	{
+		name:    "set positions home",
+		hex:     "55aa13fff3010000020000030000040000050000060000" + "1a",
+		frame:   SetPositionsFrame(BroadcastAddress, Positions{}),
+		message: Positions{},
+	},
+	{
+		name:    "set positions extremes",
+		hex:     "55aa13fff3010100" + "02ffff" + "030001" + "04ff7f" + "050080" + "069001" + "a9",
+		frame:   SetPositionsFrame(BroadcastAddress, Positions{1, -1, 256, 32767, -32768, 400}),
+		message: Positions{1, -1, 256, 32767, -32768, 400},
+	},
+}
+
+func TestGoldenFrames(t *testing.T) {
+	testGoldenFrames(t, goldenFrames, ParseMessage)
+}
+
+func testGoldenFrames(t *testing.T, frames []goldenFrame, parse func(Frame) (Message, error)) {
+	for _, golden := range frames {
+		data, err := hex.DecodeString(golden.hex)
+		require.NoError(t, err, golden.name)
+
+		encoded, err := golden.frame.Encode()
+		assert.NoError(t, err, golden.name)
+		assert.Equal(t, golden.hex, hex.EncodeToString(encoded), golden.name)
+
+		frame, n, err := Decode(data)
+		assert.NoError(t, err, golden.name)
+		assert.Equal(t, len(data), n, golden.name)
+		assert.Equal(t, golden.frame, frame, golden.name)
+
+		message, err := parse(frame)
+		assert.NoError(t, err, golden.name)
+		assert.Equal(t, golden.message, message, golden.name)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data, _ := hex.DecodeString(goldenFrames[0].hex)
+
+	_, _, err := Decode(data[:10])
+	assert.Equal(t, ErrIncomplete, err)
+
+	_, _, err = Decode(data[1:])
+	assert.Equal(t, ErrHeader, err)
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[7]++
+	_, n, err := Decode(corrupt)
+	assert.True(t, errors.Is(err, ErrChecksum))
+	assert.Equal(t, len(data), n)
+
+	_, _, err = Decode([]byte{Header0, Header1, 0})
+	assert.Equal(t, ErrLength, err)
+
+	_, err = ParseMessage(Frame{Command: 0x10})
+	assert.True(t, errors.Is(err, ErrCommand))
+	_, err = ParseMessage(Frame{Command: CommandHome})
+	assert.True(t, errors.Is(err, ErrCommand))
+	_, err = ParseMessage(Frame{Command: CommandSetPositions, Payload: []byte{2, 0, 0}})
+	assert.True(t, errors.Is(err, ErrPayload))
+	payload := Positions{}.Payload()
+	payload[3] = 5
+	_, err = ParsePositions(payload)
+	assert.True(t, errors.Is(err, ErrPayload))
+}
+
+func TestDecoder(t *testing.T) {
+	var stream bytes.Buffer
+	stream.WriteString("\x00\x55\x13")
+	for _, golden := range []goldenFrame{goldenFrames[0], goldenFrames[1], goldenFrames[0]} {
+		data, _ := hex.DecodeString(golden.hex)
+		stream.Write(data)
+	}
+	// Corrupt the checksum of the second frame.
+	raw := stream.Bytes()
+	raw[3+23+22]++
+
+	decoder := NewDecoder(&stream)
+	frame, err := decoder.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, goldenFrames[0].frame, frame)
+	assert.Equal(t, 3, decoder.Skipped)
+
+	_, err = decoder.ReadFrame()
+	assert.True(t, errors.Is(err, ErrChecksum))
+
+	frame, err = decoder.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, goldenFrames[0].frame, frame)
+
+	_, err = decoder.ReadFrame()
+	assert.Equal(t, io.EOF, err)
+
+	data, _ := hex.DecodeString(goldenFrames[0].hex)
+	_, err = NewDecoder(bytes.NewReader(data[:10])).ReadFrame()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestCount(t *testing.T) {
+	for _, c := range []struct {
+		length float64
+		count  int16
+	}{
+		{StrokeZero, 0},
+		{StrokeZero + 0.01, 400},
+		{StrokeZero - 0.01, -400},
+		{StrokeZero + 32767.0/StrokeScale, 32767},
+	} {
+		count, err := Count(c.length)
+		assert.NoError(t, err)
+		assert.Equal(t, c.count, count)
+		assert.InDelta(t, c.length, Length(count), 1.0/StrokeScale)
+	}
+
+	// Overflowing lengths are rejected instead of wrapping around.
+	for _, length := range []float64{StrokeZero + 1, StrokeZero - 1} {
+		_, err := Count(length)
+		assert.True(t, errors.Is(err, ErrOverflow))
+	}
+	_, err := PositionsFromLengths([]float32{0.1569, 0.1569, 2, 0.1569, 0.1569, 0.1569})
+	assert.True(t, errors.Is(err, ErrOverflow))
+	assert.True(t, strings.HasPrefix(err.Error(), "cylinder 3"))
+
+	_, err = Frame{Payload: make([]byte, MaxPayload+1)}.Encode()
+	assert.True(t, errors.Is(err, ErrOverflow))
+}
+
+func TestCountRoundTrip(t *testing.T) {
+	// The cylinder lengths reach the codec as float32; truncating their counts
+	// would put 0.1669 m at 399.
+	for count := -800; count <= 800; count++ {
+		length := float32(Length(int16(count)))
+		encoded, err := Count(float64(length))
+		assert.NoError(t, err)
+		assert.Equal(t, int16(count), encoded, "length %v", length)
+	}
+	encoded, _ := Count(float64(float32(0.1669)))
+	assert.Equal(t, int16(400), encoded)
+}
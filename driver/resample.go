@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/rotation"
+)
+
+// ResampleTrack returns the track resampled to rate Hz. Rotations are
+// interpolated with quaternion SLERP, translations and the IP, LC and RC
+// points linearly. Point lists that do not have one point per frame are kept
+// as they are.
+func ResampleTrack(track TrackData, rate int) (TrackData, error) {
+	if rate <= 0 {
+		return track, fmt.Errorf("invalid rate %d", rate)
+	}
+	frequency := track.Frequency
+	if frequency <= 0 {
+		frequency = DefaultFrequency
+	}
+	frames := len(track.MatrixList)
+	if frames == 0 {
+		return track, errors.New("track has no frames")
+	}
+	if frequency == rate {
+		return track, nil
+	}
+
+	duration := float64(frames-1) / float64(frequency)
+	count := int(math.Floor(duration*float64(rate)+1e-9)) + 1
+
+	resampled := track
+	resampled.Frequency = rate
+	resampled.Size = count
+	resampled.MatrixList = make([][4][4]float64, count)
+	resampled.IPList = resamplePoints(track.IPList, frames, count, frequency, rate)
+	resampled.LCList = resamplePoints(track.LCList, frames, count, frequency, rate)
+	resampled.RCList = resamplePoints(track.RCList, frames, count, frequency, rate)
+	for j := 0; j < count; j++ {
+		i, alpha := sourcePosition(j, frames, frequency, rate)
+		resampled.MatrixList[j] = interpolateMatrix(track.MatrixList[i], track.MatrixList[min(i+1, frames-1)], alpha)
+	}
+	return resampled, nil
+}
+
+// sourcePosition returns the source frame before output frame j and the
+// fraction of the way to the next source frame.
+func sourcePosition(j, frames, frequency, rate int) (int, float64) {
+	position := float64(j) * float64(frequency) / float64(rate)
+	i := int(math.Floor(position))
+	if i >= frames-1 {
+		return frames - 1, 0
+	}
+	return i, position - float64(i)
+}
+
+func resamplePoints(points [][3]float64, frames, count, frequency, rate int) [][3]float64 {
+	if len(points) != frames {
+		return points
+	}
+	resampled := make([][3]float64, count)
+	for j := range resampled {
+		i, alpha := sourcePosition(j, frames, frequency, rate)
+		next := points[min(i+1, frames-1)]
+		for k := 0; k < 3; k++ {
+			resampled[j][k] = points[i][k] + alpha*(next[k]-points[i][k])
+		}
+	}
+	return resampled
+}
+
+// interpolateMatrix blends two homogeneous transforms: the rotation with SLERP
+// and the translation linearly.
+func interpolateMatrix(a, b [4][4]float64, alpha float64) [4][4]float64 {
+	if alpha == 0 {
+		return a
+	}
+	var ra, rb rotation.Matrix
+	for i := 0; i < 3; i++ {
+		copy(ra[i][:], a[i][:3])
+		copy(rb[i][:], b[i][:3])
+	}
+	r := rotation.Slerp(rotation.FromMatrix(ra), rotation.FromMatrix(rb), alpha).Matrix()
+
+	var result [4][4]float64
+	for i := 0; i < 3; i++ {
+		copy(result[i][:3], r[i][:])
+		result[i][3] = a[i][3] + alpha*(b[i][3]-a[i][3])
+	}
+	for k := 0; k < 4; k++ {
+		result[3][k] = a[3][k] + alpha*(b[3][k]-a[3][k])
+	}
+	return result
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func transformZ(degrees, x float64) [4][4]float64 {
+	rad := degrees * math.Pi / 180
+	return [4][4]float64{
+		{math.Cos(rad), -math.Sin(rad), 0, x},
+		{math.Sin(rad), math.Cos(rad), 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+func assertTransformInDelta(t *testing.T, expected, actual [4][4]float64) {
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			assert.InDelta(t, expected[i][j], actual[i][j], 1e-9, "element %d,%d", i, j)
+		}
+	}
+}
+
+func TestResampleTrack(t *testing.T) {
+	track := TrackData{
+		Size:       3,
+		Frequency:  10,
+		MatrixList: [][4][4]float64{transformZ(0, 0), transformZ(10, 1), transformZ(20, 2)},
+		IPList:     [][3]float64{{0, 0, 0}, {1, 2, 3}, {2, 4, 6}},
+	}
+
+	resampled, err := ResampleTrack(track, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, resampled.Frequency)
+	assert.Equal(t, 5, resampled.Size)
+	assert.Len(t, resampled.MatrixList, 5)
+	for j, item := range resampled.MatrixList {
+		assertTransformInDelta(t, transformZ(float64(j)*5, float64(j)*0.5), item)
+	}
+	assert.Equal(t, [3]float64{0.5, 1, 1.5}, resampled.IPList[1])
+	assert.Nil(t, resampled.LCList)
+
+	downsampled, err := ResampleTrack(track, 5)
+	assert.NoError(t, err)
+	assert.Len(t, downsampled.MatrixList, 2)
+	assertTransformInDelta(t, transformZ(20, 2), downsampled.MatrixList[1])
+
+	_, err = ResampleTrack(TrackData{}, 5)
+	assert.Error(t, err)
+}
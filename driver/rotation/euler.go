@@ -0,0 +1,224 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rotation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultSingularityTolerance is how close |sin| of the middle angle may get
+// to 1 before the decomposition is treated as gimbal locked.
+const DefaultSingularityTolerance = 1e-6
+
+// Sequence is a Tait-Bryan rotation order. Upper case names such as "ZYX" are
+// intrinsic (rotations about the moving axes, applied left to right), lower
+// case names such as "xyz" are extrinsic (rotations about the fixed axes).
+// "ZYX" and "xyz" are the same rotation.
+type Sequence struct {
+	Name      string
+	axes      [3]int
+	intrinsic bool
+}
+
+// ParseSequence parses a sequence name such as "ZYX" or "xyz".
+func ParseSequence(name string) (Sequence, error) {
+	sequence := Sequence{Name: name}
+	if len(name) != 3 {
+		return sequence, fmt.Errorf("invalid rotation sequence %q", name)
+	}
+	switch name {
+	case strings.ToUpper(name):
+		sequence.intrinsic = true
+	case strings.ToLower(name):
+	default:
+		return sequence, fmt.Errorf("rotation sequence %q mixes intrinsic and extrinsic axes", name)
+	}
+	seen := map[int]bool{}
+	for i, axis := range strings.ToLower(name) {
+		index := int(axis - 'x')
+		if index < 0 || index > 2 || seen[index] {
+			return sequence, fmt.Errorf("invalid rotation sequence %q", name)
+		}
+		seen[index] = true
+		sequence.axes[i] = index
+	}
+	return sequence, nil
+}
+
+// MustParseSequence is ParseSequence for constant names.
+func MustParseSequence(name string) Sequence {
+	sequence, err := ParseSequence(name)
+	if err != nil {
+		panic(err)
+	}
+	return sequence
+}
+
+// Axes returns the axis indexes (0 for X) in sequence order.
+func (s Sequence) Axes() [3]int {
+	return s.axes
+}
+
+// intrinsicAxes returns the equivalent intrinsic axis order; an extrinsic
+// sequence is the intrinsic one in reverse.
+func (s Sequence) intrinsicAxes() [3]int {
+	if s.intrinsic {
+		return s.axes
+	}
+	return [3]int{s.axes[2], s.axes[1], s.axes[0]}
+}
+
+// Matrix composes the rotation of the angles in degrees, in sequence order.
+func (s Sequence) Matrix(angles [3]float64) Matrix {
+	axes := s.intrinsicAxes()
+	if !s.intrinsic {
+		angles = [3]float64{angles[2], angles[1], angles[0]}
+	}
+	m := AxisRotation(axes[0], angles[0])
+	m = m.Mul(AxisRotation(axes[1], angles[1]))
+	return m.Mul(AxisRotation(axes[2], angles[2]))
+}
+
+// Euler decomposes m into angles in degrees, in sequence order. When the
+// middle angle is within tolerance of ±90 degrees the outer axes line up and
+// only their sum is defined: the third intrinsic angle (the first extrinsic
+// one) is then taken from hint, or zero, and the other carries the rest.
+func (s Sequence) Euler(m Matrix, tolerance float64, hint *[3]float64) [3]float64 {
+	axes := s.intrinsicAxes()
+	if hint != nil && !s.intrinsic {
+		hint = &[3]float64{hint[2], hint[1], hint[0]}
+	}
+	angles := intrinsicEuler(m, axes, tolerance, hint)
+	if !s.intrinsic {
+		angles = [3]float64{angles[2], angles[1], angles[0]}
+	}
+	return angles
+}
+
+func intrinsicEuler(m Matrix, axes [3]int, tolerance float64, hint *[3]float64) [3]float64 {
+	i, j, k := axes[0], axes[1], axes[2]
+	sign := -1.0
+	if (j-i+3)%3 == 1 {
+		sign = 1
+	}
+
+	var angles [3]float64
+	sinMiddle := sign * m[i][k]
+	if math.Abs(sinMiddle) < 1-tolerance {
+		angles[1] = math.Asin(sinMiddle)
+		angles[0] = math.Atan2(-sign*m[j][k], m[k][k])
+		angles[2] = math.Atan2(-sign*m[i][j], m[i][i])
+		return toDegrees(angles)
+	}
+
+	// Gimbal lock: R_j(±90) R_k(θ3) R_j(±90)ᵀ is a rotation about axis i, so
+	// m R_k(θ3)ᵀ R_j(θ2)ᵀ is a pure rotation about i.
+	angles[1] = math.Copysign(math.Pi/2, sinMiddle)
+	if hint != nil {
+		angles[2] = hint[2] * math.Pi / 180
+	}
+	rest := m.Mul(AxisRotation(k, angles[2]*180/math.Pi).Transpose()).
+		Mul(AxisRotation(j, angles[1]*180/math.Pi).Transpose())
+	p, q := (i+1)%3, (i+2)%3
+	angles[0] = math.Atan2(rest[q][p], rest[p][p])
+	return toDegrees(angles)
+}
+
+// AxisRotation returns the rotation of angle degrees about axis 0, 1 or 2.
+func AxisRotation(axis int, angle float64) Matrix {
+	rad := angle * math.Pi / 180
+	c, s := math.Cos(rad), math.Sin(rad)
+	p, q := (axis+1)%3, (axis+2)%3
+	var m Matrix
+	m[axis][axis] = 1
+	m[p][p], m[p][q] = c, -s
+	m[q][p], m[q][q] = s, c
+	return m
+}
+
+// Mul returns m times n.
+func (m Matrix) Mul(n Matrix) Matrix {
+	var result Matrix
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				result[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return result
+}
+
+// Transpose returns the transpose, the inverse of a rotation.
+func (m Matrix) Transpose() Matrix {
+	var result Matrix
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			result[i][j] = m[j][i]
+		}
+	}
+	return result
+}
+
+func toDegrees(angles [3]float64) [3]float64 {
+	for i := range angles {
+		angles[i] = angles[i] * 180 / math.Pi
+	}
+	return angles
+}
+
+// EulerConverter decomposes a stream of rotations into Euler angles that stay
+// continuous from one frame to the next: angles are unwrapped across ±180
+// degrees and gimbal locked frames keep the last angle of the previous frame.
+// The middle angle stays within ±90 degrees: the bow maps the angles linearly,
+// so the mirrored solution, the same rotation, would be another bow pose.
+type EulerConverter struct {
+	Sequence  Sequence
+	Tolerance float64
+	previous  *[3]float64
+}
+
+// NewEulerConverter returns a converter for the sequence with the default
+// singularity tolerance.
+func NewEulerConverter(sequence Sequence) *EulerConverter {
+	return &EulerConverter{Sequence: sequence, Tolerance: DefaultSingularityTolerance}
+}
+
+// Reset forgets the previous frame.
+func (c *EulerConverter) Reset() {
+	c.previous = nil
+}
+
+// Convert returns the angles of m in degrees, in sequence order.
+func (c *EulerConverter) Convert(m Matrix) [3]float64 {
+	angles := c.Sequence.Euler(m, c.Tolerance, c.previous)
+	if c.previous != nil {
+		angles = Unwrap(*c.previous, angles)
+	}
+	c.previous = &angles
+	return angles
+}
+
+// Unwrap shifts every angle by whole turns to be closest to previous.
+func Unwrap(previous, angles [3]float64) [3]float64 {
+	for i := range angles {
+		angles[i] -= 360 * math.Round((angles[i]-previous[i])/360)
+	}
+	return angles
+}
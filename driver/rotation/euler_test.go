@@ -0,0 +1,98 @@
+package rotation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sequences = []string{"XYZ", "XZY", "YXZ", "YZX", "ZXY", "ZYX", "xyz", "xzy", "yxz", "yzx", "zxy", "zyx"}
+
+func TestParseSequence(t *testing.T) {
+	for _, name := range sequences {
+		_, err := ParseSequence(name)
+		assert.NoError(t, err, name)
+	}
+	for _, name := range []string{"", "XY", "XYX", "XyZ", "ABC", "XYZW"} {
+		_, err := ParseSequence(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestEulerRoundTrip(t *testing.T) {
+	for _, name := range sequences {
+		s := MustParseSequence(name)
+		for _, angles := range [][3]float64{{0, 0, 0}, {10, 20, 30}, {-150, 60, 170}, {45, -89, -45}} {
+			actual := s.Euler(s.Matrix(angles), DefaultSingularityTolerance, nil)
+			assert.InDeltaSlice(t, angles[:], actual[:], 1e-6, "%s %v", name, angles)
+		}
+	}
+}
+
+func TestEulerIntrinsicMatchesReversedExtrinsic(t *testing.T) {
+	angles := [3]float64{10, 20, 30}
+	assertMatrixInDelta(t, MustParseSequence("ZYX").Matrix(angles),
+		MustParseSequence("xyz").Matrix([3]float64{30, 20, 10}))
+}
+
+func TestEulerGimbalLock(t *testing.T) {
+	for _, name := range sequences {
+		s := MustParseSequence(name)
+		// Near singular frames are not exactly ±1 but must still take the
+		// gimbal lock branch.
+		expected := [3]float64{40, 89.95, 25}
+		fixed := 2
+		if !s.intrinsic {
+			fixed = 0
+		}
+		m := s.Matrix(expected)
+		angles := s.Euler(m, DefaultSingularityTolerance, nil)
+		assert.Equal(t, 90.0, angles[1], name)
+		assert.Equal(t, 0.0, angles[fixed], name)
+		assertMatrixNear(t, m, s.Matrix(angles), 1e-3)
+
+		hint := [3]float64{}
+		hint[fixed] = expected[fixed]
+		angles = s.Euler(m, DefaultSingularityTolerance, &hint)
+		assert.InDeltaSlice(t, []float64{40, 90, 25}, angles[:], 0.1, name)
+	}
+}
+
+func TestEulerConverterContinuity(t *testing.T) {
+	s := MustParseSequence("ZYX")
+	converter := NewEulerConverter(s)
+	var previous [3]float64
+	for frame := 0; frame <= 40; frame++ {
+		// Yaw crosses 180 degrees.
+		expected := [3]float64{170 + float64(frame), 60 + float64(frame)/2, 5}
+		angles := converter.Convert(s.Matrix(expected))
+		assertMatrixInDelta(t, s.Matrix(expected), s.Matrix(angles))
+		if frame > 0 {
+			for i := range angles {
+				assert.InDelta(t, previous[i], angles[i], 3, "frame %d angle %d", frame, i)
+			}
+		}
+		previous = angles
+	}
+	assert.InDelta(t, 210, previous[0], 1e-6)
+	assert.InDelta(t, 80, previous[1], 1e-6)
+
+	// Past 90 degrees the pitch folds back to 80, yaw and roll turning by 180,
+	// rather than going on to 100, which the bow would map to another pose.
+	expected := [3]float64{210, 100, 5}
+	angles := converter.Convert(s.Matrix(expected))
+	assertMatrixInDelta(t, s.Matrix(expected), s.Matrix(angles))
+	assert.InDelta(t, 80, angles[1], 1e-6)
+}
+
+func TestUnwrap(t *testing.T) {
+	assert.Equal(t, [3]float64{181, -190, 10}, Unwrap([3]float64{179, -170, 0}, [3]float64{-179, 170, 10}))
+}
+
+func assertMatrixNear(t *testing.T, expected, actual Matrix, delta float64) {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			assert.InDelta(t, expected[i][j], actual[i][j], delta, "element %d,%d", i, j)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rotation holds the rotation helpers shared by the driver.
+package rotation
+
+import (
+	"math"
+)
+
+// Matrix is a 3x3 rotation matrix, row major.
+type Matrix [3][3]float64
+
+// Quaternion is a unit quaternion W + Xi + Yj + Zk.
+type Quaternion struct {
+	W, X, Y, Z float64
+}
+
+// FromMatrix converts a rotation matrix into a unit quaternion.
+func FromMatrix(m Matrix) Quaternion {
+	var q Quaternion
+	trace := m[0][0] + m[1][1] + m[2][2]
+	switch {
+	case trace > 0:
+		s := 2 * math.Sqrt(trace+1)
+		q = Quaternion{
+			W: s / 4,
+			X: (m[2][1] - m[1][2]) / s,
+			Y: (m[0][2] - m[2][0]) / s,
+			Z: (m[1][0] - m[0][1]) / s,
+		}
+	case m[0][0] > m[1][1] && m[0][0] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[0][0]-m[1][1]-m[2][2])
+		q = Quaternion{
+			W: (m[2][1] - m[1][2]) / s,
+			X: s / 4,
+			Y: (m[0][1] + m[1][0]) / s,
+			Z: (m[0][2] + m[2][0]) / s,
+		}
+	case m[1][1] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[1][1]-m[0][0]-m[2][2])
+		q = Quaternion{
+			W: (m[0][2] - m[2][0]) / s,
+			X: (m[0][1] + m[1][0]) / s,
+			Y: s / 4,
+			Z: (m[1][2] + m[2][1]) / s,
+		}
+	default:
+		s := 2 * math.Sqrt(1+m[2][2]-m[0][0]-m[1][1])
+		q = Quaternion{
+			W: (m[1][0] - m[0][1]) / s,
+			X: (m[0][2] + m[2][0]) / s,
+			Y: (m[1][2] + m[2][1]) / s,
+			Z: s / 4,
+		}
+	}
+	return q.Normalize()
+}
+
+// Matrix converts the quaternion into a rotation matrix.
+func (q Quaternion) Matrix() Matrix {
+	q = q.Normalize()
+	w, x, y, z := q.W, q.X, q.Y, q.Z
+	return Matrix{
+		{1 - 2*(y*y+z*z), 2 * (x*y - z*w), 2 * (x*z + y*w)},
+		{2 * (x*y + z*w), 1 - 2*(x*x+z*z), 2 * (y*z - x*w)},
+		{2 * (x*z - y*w), 2 * (y*z + x*w), 1 - 2*(x*x+y*y)},
+	}
+}
+
+// Dot returns the four dimensional dot product of q and r.
+func (q Quaternion) Dot(r Quaternion) float64 {
+	return q.W*r.W + q.X*r.X + q.Y*r.Y + q.Z*r.Z
+}
+
+// Normalize scales q to unit length.
+func (q Quaternion) Normalize() Quaternion {
+	n := math.Sqrt(q.Dot(q))
+	if n == 0 {
+		return Quaternion{W: 1}
+	}
+	return Quaternion{q.W / n, q.X / n, q.Y / n, q.Z / n}
+}
+
+// Slerp interpolates along the shortest arc from q to r; t=0 is q and t=1 is r.
+func Slerp(q, r Quaternion, t float64) Quaternion {
+	q, r = q.Normalize(), r.Normalize()
+	dot := q.Dot(r)
+	// q and -r are the same rotation; take the short way round.
+	if dot < 0 {
+		r = Quaternion{-r.W, -r.X, -r.Y, -r.Z}
+		dot = -dot
+	}
+	// Nearly parallel: fall back to a normalized linear interpolation.
+	if dot > 0.9995 {
+		return Quaternion{
+			q.W + t*(r.W-q.W),
+			q.X + t*(r.X-q.X),
+			q.Y + t*(r.Y-q.Y),
+			q.Z + t*(r.Z-q.Z),
+		}.Normalize()
+	}
+	theta := math.Acos(dot)
+	sinTheta := math.Sin(theta)
+	a := math.Sin((1-t)*theta) / sinTheta
+	b := math.Sin(t*theta) / sinTheta
+	return Quaternion{
+		a*q.W + b*r.W,
+		a*q.X + b*r.X,
+		a*q.Y + b*r.Y,
+		a*q.Z + b*r.Z,
+	}
+}
@@ -0,0 +1,51 @@
+package rotation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rotationZ(degrees float64) Matrix {
+	rad := degrees * math.Pi / 180
+	return Matrix{
+		{math.Cos(rad), -math.Sin(rad), 0},
+		{math.Sin(rad), math.Cos(rad), 0},
+		{0, 0, 1},
+	}
+}
+
+func assertMatrixInDelta(t *testing.T, expected, actual Matrix) {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			assert.InDelta(t, expected[i][j], actual[i][j], 1e-9, "element %d,%d", i, j)
+		}
+	}
+}
+
+func TestQuaternionRoundTrip(t *testing.T) {
+	for _, m := range []Matrix{
+		rotationZ(0),
+		rotationZ(30),
+		rotationZ(180),
+		{{1, 0, 0}, {0, -1, 0}, {0, 0, -1}},
+		{{0, 0, 1}, {0, 1, 0}, {-1, 0, 0}},
+	} {
+		assertMatrixInDelta(t, m, FromMatrix(m).Matrix())
+	}
+}
+
+func TestSlerp(t *testing.T) {
+	q := FromMatrix(rotationZ(0))
+	r := FromMatrix(rotationZ(90))
+
+	assertMatrixInDelta(t, rotationZ(0), Slerp(q, r, 0).Matrix())
+	assertMatrixInDelta(t, rotationZ(90), Slerp(q, r, 1).Matrix())
+	assertMatrixInDelta(t, rotationZ(45), Slerp(q, r, 0.5).Matrix())
+	assertMatrixInDelta(t, rotationZ(22.5), Slerp(q, r, 0.25).Matrix())
+
+	// The sign of r must not send the interpolation the long way round.
+	negative := Quaternion{-r.W, -r.X, -r.Y, -r.Z}
+	assertMatrixInDelta(t, rotationZ(45), Slerp(q, negative, 0.5).Matrix())
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Bounds of the playback speed multiplier.
+const (
+	MinPlaybackSpeed = 0.25
+	MaxPlaybackSpeed = 2
+)
+
+// ErrMotionLimit is returned when the playback options would move an axis
+// beyond its velocity, acceleration or jerk limit.
+var ErrMotionLimit = errors.New("playback exceeds the motion limits")
+
+// PlaybackOptions select how a segment is played. A pass plays the frames of
+// the window [Start, End), reversed with Reverse, and back again with
+// PingPong; the pass is repeated Loops times, or until stopped when
+// Continuous.
+type PlaybackOptions struct {
+	// Speed multiplies the playback rate, 1 when zero.
+	Speed float64
+	// Loops is the number of passes, 1 when zero.
+	Loops      int
+	Continuous bool
+	PingPong   bool
+	Reverse    bool
+	// Start and End are the first frame and the frame after the last one of
+	// the window; End is the end of the segment when zero.
+	Start int
+	End   int
+}
+
+// SpeedFactor returns the playback speed multiplier.
+func (o PlaybackOptions) SpeedFactor() float64 {
+	if o.Speed == 0 {
+		return 1
+	}
+	return o.Speed
+}
+
+// Passes returns how many times the pass is played, 0 until stopped.
+func (o PlaybackOptions) Passes() int {
+	switch {
+	case o.Continuous:
+		return 0
+	case o.Loops == 0:
+		return 1
+	}
+	return o.Loops
+}
+
+// Validate checks the options against a segment of frames.
+func (o PlaybackOptions) Validate(frames int) error {
+	if speed := o.SpeedFactor(); math.IsNaN(speed) || speed < MinPlaybackSpeed || speed > MaxPlaybackSpeed {
+		return fmt.Errorf("speed %v out of [%v, %v]", o.Speed, MinPlaybackSpeed, MaxPlaybackSpeed)
+	}
+	if o.Loops < 0 {
+		return fmt.Errorf("negative loop count %d", o.Loops)
+	}
+	if o.Continuous && o.Loops != 0 {
+		return fmt.Errorf("loops and continuous are exclusive")
+	}
+	end := o.End
+	if end == 0 {
+		end = frames
+	}
+	if o.Start < 0 || end > frames || o.Start >= end {
+		return fmt.Errorf("window [%d, %d) out of the %d frames of the segment", o.Start, o.End, frames)
+	}
+	return nil
+}
+
+// Indexes returns the segment frame played at every frame of one pass over a
+// segment of frames.
+func (o PlaybackOptions) Indexes(frames int) ([]int, error) {
+	if err := o.Validate(frames); err != nil {
+		return nil, err
+	}
+	end := o.End
+	if end == 0 {
+		end = frames
+	}
+	indexes := make([]int, 0, 2*(end-o.Start))
+	for i := o.Start; i < end; i++ {
+		if o.Reverse {
+			indexes = append(indexes, end-1-(i-o.Start))
+		} else {
+			indexes = append(indexes, i)
+		}
+	}
+	if o.PingPong {
+		for i := len(indexes) - 2; i >= 0; i-- {
+			indexes = append(indexes, indexes[i])
+		}
+	}
+	return indexes, nil
+}
+
+// Pass returns the poses of one pass over poses.
+func (o PlaybackOptions) Pass(poses [][]float32) ([][]float32, error) {
+	indexes, err := o.Indexes(len(poses))
+	if err != nil {
+		return nil, err
+	}
+	pass := make([][]float32, len(indexes))
+	for i, index := range indexes {
+		pass[i] = poses[index]
+	}
+	return pass, nil
+}
+
+// CheckMotion checks that a pass played at frequency Hz times the speed stays
+// within the velocity, acceleration and jerk limits, which grow with the speed,
+// its square and its cube. The move from the end of a pass back to its start is
+// a seek transition, see PlayPasses.
+func (o PlaybackOptions) CheckMotion(pass [][]float32, frequency float64, limits MotionLimits) error {
+	speed := o.SpeedFactor()
+	rate := frequency * speed
+	derivatives := []struct {
+		name   string
+		limits [6]float64
+	}{
+		{"velocity", limits.Velocity},
+		{"acceleration", limits.Acceleration},
+		{"jerk", limits.Jerk},
+	}
+	// worst is how many times too fast the pass is, the root of the ratio of
+	// a derivative to its limit by its order.
+	worst, worstAxis, worstFrame, worstName := 0.0, 0, 0, ""
+	for order := 1; order <= len(derivatives); order++ {
+		derivative := derivatives[order-1]
+		for i := order; i < len(pass); i++ {
+			for axis := range pass[i] {
+				if axis >= len(derivative.limits) || derivative.limits[axis] <= 0 {
+					continue
+				}
+				// The poses are float32: every one may be half a unit in
+				// the last place off, which the difference sums 2^order
+				// times, so that the profiled poses pass at the track rate.
+				step := math.Abs(difference(pass, i, axis, order)) - math.Ldexp(largest(pass, i, axis, order), order-24)
+				value := math.Max(step, 0) * math.Pow(rate, float64(order))
+				excess := math.Pow(value/derivative.limits[axis], 1/float64(order))
+				if excess > worst {
+					worst, worstAxis, worstFrame, worstName = excess, axis, i, derivative.name
+				}
+			}
+		}
+	}
+	if worst <= 1 {
+		return nil
+	}
+	return fmt.Errorf("%w: axis %d %s is over its limit at frame %d of the pass, the speed can be at most %.2f",
+		ErrMotionLimit, worstAxis, worstName, worstFrame, speed/worst)
+}
+
+// largest returns the largest magnitude of the axis over frames i-order to i
+// of poses.
+func largest(poses [][]float32, i, axis, order int) float64 {
+	var magnitude float64
+	for j := i - order; j <= i; j++ {
+		magnitude = math.Max(magnitude, math.Abs(float64(poses[j][axis])))
+	}
+	return magnitude
+}
+
+// difference returns the backward difference of the order of the axis at
+// frame i of poses.
+func difference(poses [][]float32, i, axis, order int) float64 {
+	if order == 0 {
+		return float64(poses[i][axis])
+	}
+	return difference(poses, i, axis, order-1) - difference(poses, i-1, axis, order-1)
+}
+
+// PlayPasses plays the pass of poses with playback as many times as options
+// say, sending every frame with send, and records the pass played in the
+// execution. A pass that does not end where it starts is followed by
+// playback.Transition from its last frame to its first, as a seek is, so that
+// a loop never jumps; otherwise the next pass starts one period later. It
+// returns the error of send, of the transition or of ctx.
+func (c *DigitalbowClient) PlayPasses(ctx context.Context, playback *Playback, options PlaybackOptions, poses [][]float32, send func(frame int) error) error {
+	last := len(poses) - 1
+	for pass := 0; options.Passes() == 0 || pass < options.Passes(); pass++ {
+		if pass > 0 {
+			if playback.Transition != nil && !samePose(poses[last], poses[0]) {
+				if err := playback.Transition(last, 0); err != nil {
+					return err
+				}
+			} else {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(playback.Period):
+				}
+			}
+		}
+		c.ExecutionPass(pass)
+		report, err := playback.RunContext(ctx, len(poses), send)
+		klog.V(1).Infof("Playback pass %d: %v", pass, report)
+		if len(report.LateFrames) != 0 {
+			klog.Warningf("Playback pass %d late frames: %v", pass, report.LateFrames)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func samePose(a, b []float32) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
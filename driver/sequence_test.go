@@ -0,0 +1,169 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ramp returns frames poses moving x by step meters per frame.
+func ramp(frames int, step float32) [][]float32 {
+	poses := make([][]float32, frames)
+	for i := range poses {
+		poses[i] = []float32{0, 0, 0, float32(i) * step, 0, 0}
+	}
+	return poses
+}
+
+func xs(poses [][]float32) []int {
+	var result []int
+	for _, pose := range poses {
+		result = append(result, int(pose[3]*1000+0.5))
+	}
+	return result
+}
+
+func TestPlaybackOptionsPass(t *testing.T) {
+	poses := ramp(5, 0.001)
+	for _, test := range []struct {
+		name    string
+		options PlaybackOptions
+		want    []int
+	}{
+		{"whole", PlaybackOptions{}, []int{0, 1, 2, 3, 4}},
+		{"window", PlaybackOptions{Start: 1, End: 4}, []int{1, 2, 3}},
+		{"reverse", PlaybackOptions{Reverse: true, Start: 2}, []int{4, 3, 2}},
+		{"ping-pong", PlaybackOptions{PingPong: true, End: 3}, []int{0, 1, 2, 1, 0}},
+		{"reverse ping-pong", PlaybackOptions{PingPong: true, Reverse: true, Start: 3}, []int{4, 3, 4}},
+	} {
+		pass, err := test.options.Pass(poses)
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.want, xs(pass), test.name)
+		indexes, err := test.options.Indexes(len(poses))
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.want, indexes, test.name)
+	}
+
+	for _, options := range []PlaybackOptions{
+		{Speed: 0.1},
+		{Speed: 3},
+		{Loops: -1},
+		{Loops: 2, Continuous: true},
+		{Start: 5},
+		{Start: 3, End: 2},
+		{End: 6},
+	} {
+		_, err := options.Pass(poses)
+		assert.Error(t, err, "%+v", options)
+	}
+}
+
+func TestPlaybackOptionsPasses(t *testing.T) {
+	assert.Equal(t, 1, PlaybackOptions{}.Passes())
+	assert.Equal(t, 3, PlaybackOptions{Loops: 3}.Passes())
+	assert.Equal(t, 0, PlaybackOptions{Continuous: true}.Passes())
+	assert.Equal(t, 1.0, PlaybackOptions{}.SpeedFactor())
+}
+
+func TestPlaybackOptionsCheckMotion(t *testing.T) {
+	// 1 mm per frame at 50 Hz is 50 mm/s.
+	pass := ramp(10, 0.001)
+	limits := MotionLimits{Velocity: [6]float64{0, 0, 0, 0.06, 0.06, 0.06}}
+
+	assert.NoError(t, PlaybackOptions{}.CheckMotion(pass, 50, limits))
+	assert.NoError(t, PlaybackOptions{Speed: 1.1}.CheckMotion(pass, 50, limits))
+	err := PlaybackOptions{Speed: 2}.CheckMotion(pass, 50, limits)
+	assert.ErrorIs(t, err, ErrMotionLimit)
+	assert.Contains(t, err.Error(), "velocity")
+	assert.Contains(t, err.Error(), "at most 1.20")
+	// A slower track time scale makes room for the speed.
+	assert.NoError(t, PlaybackOptions{Speed: 2}.CheckMotion(pass, 25, limits))
+
+	// Looping moves back from the end to the start as a seek does.
+	assert.NoError(t, PlaybackOptions{Loops: 2}.CheckMotion(pass, 50, limits))
+	pingPong, err := PlaybackOptions{PingPong: true}.Pass(pass)
+	require.NoError(t, err)
+	assert.NoError(t, PlaybackOptions{Continuous: true, PingPong: true}.CheckMotion(pingPong, 50, limits))
+
+	assert.NoError(t, PlaybackOptions{Speed: 2}.CheckMotion(pass, 50, MotionLimits{}))
+}
+
+func TestPlaybackOptionsCheckMotionDerivatives(t *testing.T) {
+	// x accelerates by 1 mm per frame squared, 2.5 m/s² at 50 Hz.
+	pass := make([][]float32, 10)
+	for i := range pass {
+		pass[i] = []float32{0, 0, 0, 0.0005 * float32(i*i), 0, 0}
+	}
+	// The profile holds the limits at the track rate; twice as fast is four
+	// times the acceleration and eight times the jerk.
+	acceleration := MotionLimits{Acceleration: [6]float64{0, 0, 0, 3, 3, 3}}
+	assert.NoError(t, PlaybackOptions{}.CheckMotion(pass, 50, acceleration))
+	err := PlaybackOptions{Speed: 2}.CheckMotion(pass, 50, acceleration)
+	assert.ErrorIs(t, err, ErrMotionLimit)
+	assert.Contains(t, err.Error(), "acceleration")
+	assert.Contains(t, err.Error(), "at most 1.10")
+
+	// Starting from rest, the acceleration steps up with a jerk of 62.5 m/s³.
+	jerk := MotionLimits{Jerk: [6]float64{0, 0, 0, 100, 100, 100}}
+	pass = append([][]float32{{0, 0, 0, 0, 0, 0}}, pass...)
+	assert.NoError(t, PlaybackOptions{}.CheckMotion(pass, 50, jerk))
+	err = PlaybackOptions{Speed: 2}.CheckMotion(pass, 50, jerk)
+	assert.ErrorIs(t, err, ErrMotionLimit)
+	assert.Contains(t, err.Error(), "jerk")
+
+	// A clamped step passes at the track rate despite float32 rounding.
+	step := make([][]float32, 100)
+	for i := range step {
+		step[i] = make([]float32, 6)
+		if i > 10 {
+			step[i] = []float32{10, 5, 0, 0.01, 0, 0}
+		}
+	}
+	limits := MotionLimits{
+		Velocity:     [6]float64{60, 60, 60, 0.1, 0.1, 0.1},
+		Acceleration: [6]float64{600, 600, 600, 1, 1, 1},
+		Jerk:         [6]float64{6000, 6000, 6000, 10, 10, 10},
+	}
+	profiled, _, err := ProfileMotion(step, 120, limits)
+	require.NoError(t, err)
+	assert.NoError(t, PlaybackOptions{}.CheckMotion(profiled, 120, limits))
+	assert.ErrorIs(t, PlaybackOptions{Speed: 1.5}.CheckMotion(profiled, 120, limits), ErrMotionLimit)
+}
+
+func TestPlayPassesMovesBackToTheStart(t *testing.T) {
+	client := newTestClient(t)
+	poses := ramp(5, 0.001)
+
+	var sent []int
+	var transitions [][2]int
+	playback := NewPlayback(1000)
+	playback.Transition = func(from, to int) error {
+		transitions = append(transitions, [2]int{from, to})
+		return nil
+	}
+	send := func(frame int) error {
+		sent = append(sent, frame)
+		return nil
+	}
+	require.NoError(t, client.PlayPasses(context.Background(), playback, PlaybackOptions{Loops: 3}, poses, send))
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 0, 1, 2, 3, 4, 0, 1, 2, 3, 4}, sent)
+	assert.Equal(t, [][2]int{{4, 0}, {4, 0}}, transitions)
+
+	// A ping-pong pass ends where it starts.
+	sent, transitions = nil, nil
+	options := PlaybackOptions{Loops: 2, PingPong: true}
+	pass, err := options.Pass(poses)
+	require.NoError(t, err)
+	require.NoError(t, client.PlayPasses(context.Background(), playback, options, pass, send))
+	assert.Len(t, sent, 18)
+	assert.Empty(t, transitions)
+
+	// A failed transition ends the playback.
+	sent = nil
+	playback.Transition = func(from, to int) error { return context.Canceled }
+	err = client.PlayPasses(context.Background(), playback, PlaybackOptions{Continuous: true}, poses, send)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, sent, 5)
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jacobsa/go-serial/serial"
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/capture"
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// Bounds of the read timeout, which the termios VTIME field holds in tenths
+// of a second.
+const (
+	minSerialTimeout = 100 * time.Millisecond
+	maxSerialTimeout = 25500 * time.Millisecond
+)
+
+// RS485Config is the RTS toggling of an RS485 transceiver. Delays are in
+// milliseconds.
+type RS485Config struct {
+	RtsHighDuringSend  bool
+	RtsHighAfterSend   bool
+	RxDuringTx         bool
+	DelayRtsBeforeSend int
+	DelayRtsAfterSend  int
+}
+
+// SerialOptions returns the options opening the configured serial port. It
+// fails on settings the serial driver can not apply instead of silently
+// falling back to other ones.
+func SerialOptions(config BowRTUConfig) (serial.OpenOptions, error) {
+	options := serial.OpenOptions{
+		PortName:        config.SerialName,
+		BaudRate:        uint(config.BaudRate),
+		DataBits:        uint(config.DataBits),
+		StopBits:        uint(config.StopBits),
+		MinimumReadSize: 1,
+	}
+	if config.SerialName == "" {
+		return options, fmt.Errorf("serial port is not configured")
+	}
+	if config.BaudRate <= 0 {
+		return options, fmt.Errorf("unsupported baud rate %d", config.BaudRate)
+	}
+	if options.DataBits == 0 {
+		options.DataBits = 8
+	}
+	if options.DataBits < 5 || options.DataBits > 8 {
+		return options, fmt.Errorf("unsupported data bits %d, expect 5 to 8", config.DataBits)
+	}
+	if options.StopBits == 0 {
+		options.StopBits = 1
+	}
+	if options.StopBits > 2 {
+		return options, fmt.Errorf("unsupported stop bits %d, expect 1 or 2", config.StopBits)
+	}
+
+	switch strings.ToLower(config.Parity) {
+	case "", "none", "n":
+		options.ParityMode = serial.PARITY_NONE
+	case "even", "e":
+		options.ParityMode = serial.PARITY_EVEN
+	case "odd", "o":
+		options.ParityMode = serial.PARITY_ODD
+	default:
+		return options, fmt.Errorf("unsupported parity %s, expect none, even or odd", config.Parity)
+	}
+
+	if config.Timeout != 0 {
+		if config.Timeout < minSerialTimeout || config.Timeout > maxSerialTimeout {
+			return options, fmt.Errorf("unsupported serial timeout %v, expect %v to %v",
+				config.Timeout, minSerialTimeout, maxSerialTimeout)
+		}
+		if config.Timeout%minSerialTimeout != 0 {
+			return options, fmt.Errorf("unsupported serial timeout %v, expect a multiple of %v",
+				config.Timeout, minSerialTimeout)
+		}
+		options.InterCharacterTimeout = uint(config.Timeout / time.Millisecond)
+	}
+
+	rs485 := config.RS485
+	if !config.RS485Enabled {
+		if rs485 != (RS485Config{}) {
+			return options, fmt.Errorf("rs485 options need serialType RS485")
+		}
+		return options, nil
+	}
+	if runtime.GOOS != "linux" {
+		return options, fmt.Errorf("RS485 mode is not supported on %s", runtime.GOOS)
+	}
+	if rs485.DelayRtsBeforeSend < 0 || rs485.DelayRtsAfterSend < 0 {
+		return options, fmt.Errorf("negative RS485 RTS delay")
+	}
+	options.Rs485Enable = true
+	options.Rs485RtsHighDuringSend = rs485.RtsHighDuringSend
+	options.Rs485RtsHighAfterSend = rs485.RtsHighAfterSend
+	options.Rs485RxDuringTx = rs485.RxDuringTx
+	options.Rs485DelayRtsBeforeSend = rs485.DelayRtsBeforeSend
+	options.Rs485DelayRtsAfterSend = rs485.DelayRtsAfterSend
+	return options, nil
+}
+
+// Connect opens the serial port in the background and keeps it open. The
+// device is Offline until the port is open. It fails at once when the serial
+// settings are not supported.
+func (c *DigitalbowClient) Connect() error {
+	options, err := SerialOptions(c.Client.Config)
+	if err != nil {
+		return err
+	}
+	klog.V(1).Infof("Serial port %s: %d baud, %d data bits, parity %v, %d stop bits, RS485 %v",
+		options.PortName, options.BaudRate, options.DataBits, options.ParityMode, options.StopBits, options.Rs485Enable)
+	if err = c.startCapture(c.Client.Config.Capture); err != nil {
+		return err
+	}
+	c.connect(func() (io.ReadWriteCloser, error) {
+		return serial.Open(options)
+	})
+	return nil
+}
+
+// ConnectWith opens the serial session through open instead of the configured
+// serial port, e.g. to a simulator, and keeps it open like Connect.
+func (c *DigitalbowClient) ConnectWith(open Opener) {
+	c.connect(open)
+}
+
+func (c *DigitalbowClient) connect(open Opener) {
+	c.mu.Lock()
+	if c.session != nil {
+		c.mu.Unlock()
+		return
+	}
+	_ = c.setStatus(common.StatusOffline, "connecting the serial port")
+	c.session = NewSession(open, c.Client.Config.Reconnect, c.sessionState)
+	session := c.session
+	c.mu.Unlock()
+	session.Start()
+	// The controller protocol has no feedback frames, only the simulator
+	// reports positions and status.
+	if c.Client.Config.ProtocolExtensions {
+		go c.readFeedback(session)
+	}
+}
+
+// sessionState follows the connection state in the device status. A device
+// busy executing or syncing stays so until the connection is lost. A device
+// that was Fault or Locked when the connection was lost is so again once it is
+// back. A device homing on start is homed before it is Ready, provided the
+// protocol extensions are enabled; otherwise nothing verifies the platform
+// position before it is Ready.
+func (c *DigitalbowClient) sessionState(state SessionState, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reason := fmt.Sprintf("serial port %v", state)
+	if err != nil {
+		reason = fmt.Sprintf("%s: %v", reason, err)
+	}
+	switch state {
+	case SessionConnected:
+		if c.Status != common.StatusOffline && c.Status != common.StatusBroken {
+			break
+		}
+		if held := c.heldStatus; held != "" {
+			_ = c.setStatus(held, fmt.Sprintf("%s, %s before the connection was lost", reason, held))
+			break
+		}
+		if c.Client.Config.Homing.OnStart && c.Client.Config.ProtocolExtensions && !c.homed {
+			if c.setStatus(common.StatusHoming, reason) == nil {
+				go c.runHoming("home on start", common.StatusReady)
+			}
+			break
+		}
+		if !c.homed {
+			klog.Warningf("Device Ready without homing: the platform position is not verified")
+		}
+		_ = c.setStatus(common.StatusReady, reason)
+	case SessionDisconnected:
+		_ = c.setStatus(common.StatusOffline, reason)
+	case SessionError:
+		_ = c.setStatus(common.StatusBroken, reason)
+	}
+}
+
+// Connected reports whether the serial port is open.
+func (c *DigitalbowClient) Connected() bool {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	return session != nil && session.Connected()
+}
+
+// Write writes a frame to the serial port.
+func (c *DigitalbowClient) Write(frame []byte) error {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil {
+		return ErrDisconnected
+	}
+	_, err := session.Write(frame)
+	if err == nil {
+		c.record(capture.Tx, frame)
+	}
+	return err
+}
+
+// Close closes the serial port and the capture.
+func (c *DigitalbowClient) Close() error {
+	c.mu.Lock()
+	session, writer := c.session, c.capture
+	c.session, c.capture = nil, nil
+	c.mu.Unlock()
+	if writer != nil {
+		if err := writer.Close(); err != nil {
+			klog.Errorf("Close serial capture: %v", err)
+		}
+	}
+	if session == nil {
+		return nil
+	}
+	return session.Close()
+}
+
+// startCapture starts recording the serial frames to path.
+func (c *DigitalbowClient) startCapture(path string) error {
+	if path == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capture != nil {
+		return nil
+	}
+	writer, err := capture.Create(path)
+	if err != nil {
+		return fmt.Errorf("create serial capture: %v", err)
+	}
+	klog.V(1).Infof("Recording serial frames to %s", path)
+	c.capture = writer
+	return nil
+}
+
+// record appends a frame to the capture, if any.
+func (c *DigitalbowClient) record(direction capture.Direction, frame []byte) {
+	c.mu.Lock()
+	writer := c.capture
+	c.mu.Unlock()
+	if writer == nil {
+		return
+	}
+	if err := writer.Record(direction, frame); err != nil {
+		klog.Errorf("Record serial frame: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/go-serial/serial"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerialOptions(t *testing.T) {
+	config := BowRTUConfig{SerialName: "/dev/ttyS0", BaudRate: 115200, DataBits: 8, StopBits: 2,
+		Parity: "even", Timeout: 500 * time.Millisecond}
+	options, err := SerialOptions(config)
+	assert.NoError(t, err)
+	assert.Equal(t, serial.PARITY_EVEN, options.ParityMode)
+	assert.Equal(t, uint(2), options.StopBits)
+	assert.Equal(t, uint(500), options.InterCharacterTimeout)
+	assert.False(t, options.Rs485Enable)
+
+	config.Parity = "O"
+	options, err = SerialOptions(config)
+	assert.NoError(t, err)
+	assert.Equal(t, serial.PARITY_ODD, options.ParityMode)
+
+	if runtime.GOOS == "linux" {
+		config.RS485Enabled = true
+		config.RS485 = RS485Config{RtsHighDuringSend: true, DelayRtsAfterSend: 2}
+		options, err = SerialOptions(config)
+		assert.NoError(t, err)
+		assert.True(t, options.Rs485Enable)
+		assert.True(t, options.Rs485RtsHighDuringSend)
+		assert.Equal(t, 2, options.Rs485DelayRtsAfterSend)
+	}
+
+	for name, change := range map[string]func(c *BowRTUConfig){
+		"mark parity":          func(c *BowRTUConfig) { c.Parity = "mark" },
+		"1.5 stop bits":        func(c *BowRTUConfig) { c.StopBits = 3 },
+		"9 data bits":          func(c *BowRTUConfig) { c.DataBits = 9 },
+		"no baud rate":         func(c *BowRTUConfig) { c.BaudRate = 0 },
+		"short timeout":        func(c *BowRTUConfig) { c.Timeout = 50 * time.Millisecond },
+		"long timeout":         func(c *BowRTUConfig) { c.Timeout = 30 * time.Second },
+		"unaligned timeout":    func(c *BowRTUConfig) { c.Timeout = 250 * time.Millisecond },
+		"rs485 without RS485":  func(c *BowRTUConfig) { c.RS485 = RS485Config{RtsHighAfterSend: true} },
+		"negative rs485 delay": func(c *BowRTUConfig) { c.RS485Enabled, c.RS485.DelayRtsBeforeSend = true, -1 },
+	} {
+		invalid := config
+		invalid.RS485Enabled, invalid.RS485 = false, RS485Config{}
+		change(&invalid)
+		_, err = SerialOptions(invalid)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// ErrDisconnected is returned by a session whose port is not open.
+var ErrDisconnected = errors.New("serial port is not connected")
+
+// Default reconnect backoff of a session.
+const (
+	DefaultReconnectInitial = 500 * time.Millisecond
+	DefaultReconnectMax     = 30 * time.Second
+)
+
+// SessionState is the connection state of a session.
+type SessionState int
+
+const (
+	// SessionConnected means the port is open.
+	SessionConnected SessionState = iota
+	// SessionDisconnected means an open port failed and is being reopened.
+	SessionDisconnected
+	// SessionError means the last attempt to open the port failed.
+	SessionError
+)
+
+func (s SessionState) String() string {
+	switch s {
+	case SessionConnected:
+		return "connected"
+	case SessionDisconnected:
+		return "disconnected"
+	default:
+		return "error"
+	}
+}
+
+// Opener opens the serial port of a session.
+type Opener func() (io.ReadWriteCloser, error)
+
+// Backoff is the delay between two attempts to open the port. It starts at
+// Initial and doubles up to Max after every failure.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Session is a long-lived serial connection that reopens its port with
+// backoff whenever opening, reading or writing fails.
+type Session struct {
+	open    Opener
+	backoff Backoff
+	// onState is called on every state change, from the session goroutine or
+	// from a failing Read or Write.
+	onState func(state SessionState, err error)
+
+	mu   sync.Mutex
+	port io.ReadWriteCloser
+	// lost wakes the session goroutine up to reopen the port.
+	lost chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewSession returns a session opening its port with open. It does not open
+// the port before Start.
+func NewSession(open Opener, backoff Backoff, onState func(state SessionState, err error)) *Session {
+	if backoff.Initial <= 0 {
+		backoff.Initial = DefaultReconnectInitial
+	}
+	if backoff.Max < backoff.Initial {
+		backoff.Max = DefaultReconnectMax
+	}
+	if onState == nil {
+		onState = func(SessionState, error) {}
+	}
+	return &Session{
+		open:    open,
+		backoff: backoff,
+		onState: onState,
+		lost:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start opens the port in the background and keeps it open until Close.
+func (s *Session) Start() {
+	go s.run()
+}
+
+func (s *Session) run() {
+	delay := s.backoff.Initial
+	for {
+		port, err := s.open()
+		if err != nil {
+			klog.Errorf("Open serial port failed, retry in %v: %v", delay, err)
+			s.onState(SessionError, err)
+			select {
+			case <-time.After(delay):
+			case <-s.done:
+				return
+			}
+			if delay *= 2; delay > s.backoff.Max {
+				delay = s.backoff.Max
+			}
+			continue
+		}
+
+		delay = s.backoff.Initial
+		s.mu.Lock()
+		s.port = port
+		s.mu.Unlock()
+		klog.V(1).Info("Serial port connected")
+		s.onState(SessionConnected, nil)
+
+		select {
+		case <-s.lost:
+		case <-s.done:
+			s.mu.Lock()
+			if s.port != nil {
+				s.port.Close()
+				s.port = nil
+			}
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// Connected reports whether the port is open.
+func (s *Session) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.port != nil
+}
+
+// Write writes b to the port. A failing port is closed and reopened.
+func (s *Session) Write(b []byte) (int, error) {
+	port, err := s.current()
+	if err != nil {
+		return 0, err
+	}
+	n, err := port.Write(b)
+	if err != nil {
+		s.fail(port, err)
+	}
+	return n, err
+}
+
+// Read reads from the port. A failing port is closed and reopened.
+func (s *Session) Read(b []byte) (int, error) {
+	port, err := s.current()
+	if err != nil {
+		return 0, err
+	}
+	n, err := port.Read(b)
+	if err != nil && err != io.EOF {
+		s.fail(port, err)
+	}
+	return n, err
+}
+
+func (s *Session) current() (io.ReadWriteCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.port == nil {
+		return nil, ErrDisconnected
+	}
+	return s.port, nil
+}
+
+// fail closes port if it is still the open one and asks for a reconnect.
+func (s *Session) fail(port io.ReadWriteCloser, err error) {
+	s.mu.Lock()
+	if s.port != port {
+		s.mu.Unlock()
+		return
+	}
+	s.port = nil
+	s.mu.Unlock()
+
+	port.Close()
+	klog.Errorf("Serial port lost: %v", err)
+	s.onState(SessionDisconnected, err)
+	select {
+	case s.lost <- struct{}{}:
+	default:
+	}
+}
+
+// Close closes the port and stops reconnecting.
+func (s *Session) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// fakePort is a serial port whose writes fail once broken.
+type fakePort struct {
+	mu      sync.Mutex
+	written bytes.Buffer
+	broken  bool
+	closed  bool
+}
+
+func (p *fakePort) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.broken {
+		return 0, errors.New("input/output error")
+	}
+	return p.written.Write(b)
+}
+
+func (p *fakePort) Read(b []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (p *fakePort) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// fakeOpener fails failures times, then opens a new fakePort every time.
+type fakeOpener struct {
+	mu       sync.Mutex
+	failures int
+	ports    []*fakePort
+}
+
+func (o *fakeOpener) open() (io.ReadWriteCloser, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.failures > 0 {
+		o.failures--
+		return nil, errors.New("no such file or directory")
+	}
+	port := &fakePort{}
+	o.ports = append(o.ports, port)
+	return port, nil
+}
+
+func (o *fakeOpener) opened() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.ports)
+}
+
+var testBackoff = Backoff{Initial: time.Millisecond, Max: 4 * time.Millisecond}
+
+func TestSessionReconnect(t *testing.T) {
+	opener := &fakeOpener{failures: 2}
+	states := make(chan SessionState, 16)
+	session := NewSession(opener.open, testBackoff, func(state SessionState, err error) { states <- state })
+	defer session.Close()
+
+	_, err := session.Write([]byte{1})
+	assert.Equal(t, ErrDisconnected, err)
+
+	session.Start()
+	assert.Equal(t, SessionError, <-states)
+	assert.Equal(t, SessionError, <-states)
+	assert.Equal(t, SessionConnected, <-states)
+	assert.True(t, session.Connected())
+
+	_, err = session.Write([]byte{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2}, opener.ports[0].written.Bytes())
+
+	opener.ports[0].mu.Lock()
+	opener.ports[0].broken = true
+	opener.ports[0].mu.Unlock()
+	_, err = session.Write([]byte{3})
+	assert.Error(t, err)
+	assert.Equal(t, SessionDisconnected, <-states)
+	assert.Equal(t, SessionConnected, <-states)
+	assert.Equal(t, 2, opener.opened())
+	assert.True(t, opener.ports[0].closed)
+
+	_, err = session.Write([]byte{4})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{4}, opener.ports[1].written.Bytes())
+}
+
+func TestClientConnectStatus(t *testing.T) {
+	client := newTestClient(t)
+	assert.Equal(t, ErrDisconnected, client.Write([]byte{1}))
+
+	opener := &fakeOpener{failures: 1}
+	client.connect(opener.open)
+	defer client.Close()
+	require.Eventually(t, client.Connected, time.Second, time.Millisecond)
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+
+	// Losing the port while executing leaves the device Offline until the
+	// port is reopened, and the end of the execution does not hide it.
+	assert.True(t, client.CompareAndSetStatus(common.StatusReady, common.StatusExecucting, "test"))
+	opener.mu.Lock()
+	opener.failures = 1000
+	opener.ports[0].broken = true
+	opener.mu.Unlock()
+	assert.Error(t, client.Write([]byte{1}))
+	assert.Equal(t, common.StatusOffline, client.GetStatus())
+	assert.False(t, client.CompareAndSetStatus(common.StatusExecucting, common.StatusReady, "test"))
+
+	require.Eventually(t, func() bool { return client.GetStatus() == common.StatusBroken }, time.Second, time.Millisecond)
+	opener.mu.Lock()
+	opener.failures = 0
+	opener.mu.Unlock()
+	require.Eventually(t, func() bool { return client.GetStatus() == common.StatusReady }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"math"
+
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+)
+
+// Solver names accepted in the kinematics configuration.
+const (
+	SolverGo      = "go"
+	SolverCgo     = "cgo"
+	SolverCompare = "compare"
+)
+
+// compareTolerance is the cylinder length difference in meters above which the
+// compare solver logs a mismatch.
+const compareTolerance = 1e-4
+
+// Solver computes the six cylinder lengths for a platform pose.
+type Solver interface {
+	Init()
+	SoluteCylinderLength(movements []float32, clylen []float32)
+}
+
+// solvers holds the constructors of the solvers compiled into the binary.
+var solvers = map[string]func(geometry kinematics.Geometry) (Solver, error){
+	SolverGo: newGoSolver,
+}
+
+// NewSolver returns the named solver. An empty name selects the libSixDof
+// solver when it is compiled in, and the pure Go solver otherwise.
+func NewSolver(name string, geometry kinematics.Geometry) (Solver, error) {
+	if name == "" {
+		name = SolverGo
+		if _, ok := solvers[SolverCgo]; ok {
+			name = SolverCgo
+		}
+	}
+	if name == SolverCompare {
+		return newCompareSolver(geometry)
+	}
+	newSolver, ok := solvers[name]
+	if !ok {
+		if name == SolverCgo {
+			return nil, fmt.Errorf("solver %s is not compiled in, build with -tags sixdof", name)
+		}
+		return nil, fmt.Errorf("unknown solver %s", name)
+	}
+	return newSolver(geometry)
+}
+
+// goSolver solves the inverse kinematics in pure Go.
+type goSolver struct {
+	platform *kinematics.Platform
+}
+
+func newGoSolver(geometry kinematics.Geometry) (Solver, error) {
+	platform, err := kinematics.NewPlatform(geometry)
+	if err != nil {
+		return nil, err
+	}
+	return &goSolver{platform: platform}, nil
+}
+
+func (s *goSolver) Init() {}
+
+func (s *goSolver) SoluteCylinderLength(movements []float32, clylen []float32) {
+	var pose kinematics.Pose
+	for i := range pose {
+		pose[i] = float64(movements[i])
+	}
+	lengths := s.platform.CylinderLengths(pose)
+	for i := range lengths {
+		clylen[i] = float32(lengths[i])
+	}
+}
+
+// compareSolver drives the bow with the cgo solver and cross-checks every
+// result against the pure Go solver.
+type compareSolver struct {
+	reference Solver
+	candidate Solver
+}
+
+func newCompareSolver(geometry kinematics.Geometry) (Solver, error) {
+	reference, err := NewSolver(SolverCgo, geometry)
+	if err != nil {
+		return nil, err
+	}
+	candidate, err := NewSolver(SolverGo, geometry)
+	if err != nil {
+		return nil, err
+	}
+	return &compareSolver{reference: reference, candidate: candidate}, nil
+}
+
+func (s *compareSolver) Init() {
+	s.reference.Init()
+	s.candidate.Init()
+}
+
+func (s *compareSolver) SoluteCylinderLength(movements []float32, clylen []float32) {
+	s.reference.SoluteCylinderLength(movements, clylen)
+	candidate := make([]float32, kinematics.Legs)
+	s.candidate.SoluteCylinderLength(movements, candidate)
+
+	var diff float64
+	for i := range candidate {
+		diff = math.Max(diff, math.Abs(float64(clylen[i]-candidate[i])))
+	}
+	if diff > compareTolerance {
+		klog.Warningf("solver mismatch %v for %v: cgo %v, go %v", diff, movements, clylen, candidate)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build sixdof
+// +build sixdof
+
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+//#cgo CFLAGS: -I./number
+//#cgo LDFLAGS: -L${SRCDIR}/number -lSixDof -lm
+//
+//#include <stdio.h>
+//#include <stdlib.h>
+//#include <math.h>
+//#include "s_6dof.h"
+import "C"
+import (
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+)
+
+func init() {
+	solvers[SolverCgo] = newCgoSolver
+}
+
+// cgoSolver calls the prebuilt libSixDof. Its geometry is compiled into the
+// library, so the configured geometry is ignored.
+type cgoSolver struct{}
+
+func newCgoSolver(geometry kinematics.Geometry) (Solver, error) {
+	return cgoSolver{}, nil
+}
+
+func (s cgoSolver) Init() {
+	C.SixDOFInit()
+}
+
+func (s cgoSolver) SoluteCylinderLength(movements []float32, clylen []float32) {
+	C.SoluteCylinderLength((*C.float)(&movements[0]), (*C.float)(&clylen[0]))
+}
@@ -0,0 +1,63 @@
+//go:build sixdof
+// +build sixdof
+
+package driver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+)
+
+// TestSolversAgree compares libSixDof with the pure Go solver on a grid of
+// poses. libSixDof compiles its geometry in, so the test needs the measured
+// geometry of the same platform, a JSON kinematics.Geometry named by
+// SIXDOF_GEOMETRY.
+func TestSolversAgree(t *testing.T) {
+	path := os.Getenv("SIXDOF_GEOMETRY")
+	if path == "" {
+		t.Skip("SIXDOF_GEOMETRY names no platform geometry")
+	}
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var geometry kinematics.Geometry
+	require.NoError(t, json.Unmarshal(data, &geometry))
+
+	reference, err := NewSolver(SolverCgo, geometry)
+	require.NoError(t, err)
+	candidate, err := NewSolver(SolverGo, geometry)
+	require.NoError(t, err)
+	reference.Init()
+	candidate.Init()
+
+	angles := []float32{-5, 0, 5}
+	offsets := []float32{-0.01, 0, 0.01}
+	for _, roll := range angles {
+		for _, pitch := range angles {
+			for _, yaw := range angles {
+				for _, x := range offsets {
+					for _, y := range offsets {
+						for _, z := range offsets {
+							pose := []float32{roll, pitch, yaw, x, y, z}
+							expected := make([]float32, kinematics.Legs)
+							actual := make([]float32, kinematics.Legs)
+							reference.SoluteCylinderLength(append([]float32(nil), pose...), expected)
+							candidate.SoluteCylinderLength(append([]float32(nil), pose...), actual)
+							for i := range expected {
+								assert.LessOrEqual(t, math.Abs(float64(expected[i]-actual[i])), compareTolerance,
+									"pose %v cylinder %d", pose, i+1)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
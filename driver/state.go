@@ -0,0 +1,145 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// maxStatusHistory is how many status transitions are kept for queries.
+const maxStatusHistory = 64
+
+// ErrIllegalTransition is returned when the device status can not move to the
+// status asked for.
+var ErrIllegalTransition = errors.New("illegal device status transition")
+
+// ErrNotLocked is returned when unlocking a device that is not Locked.
+var ErrNotLocked = errors.New("device is not locked")
+
+// statusTransitions lists the statuses each status may move to. Any status may
+// also move to Offline or Broken when the serial port is lost, and back to the
+// Fault or Locked status it had then.
+var statusTransitions = map[common.DeviceStatus][]common.DeviceStatus{
+	common.StatusOffline:    {common.StatusReady, common.StatusHoming},
+	common.StatusBroken:     {common.StatusReady, common.StatusHoming},
+	common.StatusReady:      {common.StatusSyncing, common.StatusExecucting, common.StatusHoming, common.StatusFault, common.StatusLocked},
+	common.StatusSyncing:    {common.StatusReady, common.StatusFault},
+	common.StatusExecucting: {common.StatusPaused, common.StatusReady, common.StatusFault},
+	common.StatusPaused:     {common.StatusExecucting, common.StatusReady, common.StatusFault},
+	common.StatusHoming:     {common.StatusReady, common.StatusFault},
+	common.StatusFault:      {common.StatusReady, common.StatusHoming, common.StatusLocked},
+	common.StatusLocked:     {common.StatusReady, common.StatusFault},
+}
+
+// StatusTransition is a change of the device status.
+type StatusTransition struct {
+	From   common.DeviceStatus `json:"from"`
+	To     common.DeviceStatus `json:"to"`
+	Reason string              `json:"reason,omitempty"`
+	Time   time.Time           `json:"time"`
+}
+
+// CanTransition reports whether the device status may move from one status to
+// another.
+func CanTransition(from, to common.DeviceStatus) bool {
+	if from == to || to == common.StatusOffline || to == common.StatusBroken {
+		return true
+	}
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves the device status to status.
+func (c *DigitalbowClient) Transition(status common.DeviceStatus, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setStatus(status, reason)
+}
+
+// Lock locks a Ready or Fault device against any motion: no job starts and
+// the platform can not be homed until Unlock.
+func (c *DigitalbowClient) Lock(reason, by string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setStatus(common.StatusLocked, fmt.Sprintf("locked by %s: %s", by, reason))
+}
+
+// Unlock moves a Locked device back to Ready, or to Fault when the platform
+// failed to home.
+func (c *DigitalbowClient) Unlock(reason, by string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Status != common.StatusLocked {
+		return fmt.Errorf("%w: device is %s", ErrNotLocked, c.Status)
+	}
+	status := common.StatusReady
+	if c.homingError != nil {
+		status = common.StatusFault
+	}
+	return c.setStatus(status, fmt.Sprintf("unlocked by %s: %s", by, reason))
+}
+
+// CompareAndSetStatus moves the device status to status if it is old, and
+// reports whether it did.
+func (c *DigitalbowClient) CompareAndSetStatus(old, status common.DeviceStatus, reason string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Status == old && c.setStatus(status, reason) == nil
+}
+
+// StatusHistory returns the last status transitions, oldest first.
+func (c *DigitalbowClient) StatusHistory() []StatusTransition {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]StatusTransition(nil), c.statusHistory...)
+}
+
+// setStatus moves the device status to status if the transition is legal and
+// records it. c.mu must be held.
+func (c *DigitalbowClient) setStatus(status common.DeviceStatus, reason string) error {
+	previous := c.Status
+	if previous == status {
+		return nil
+	}
+	restore := (previous == common.StatusOffline || previous == common.StatusBroken) && status == c.heldStatus
+	if !restore && !CanTransition(previous, status) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, previous, status)
+	}
+	switch {
+	case status != common.StatusOffline && status != common.StatusBroken:
+		c.heldStatus = ""
+	case previous == common.StatusFault || previous == common.StatusLocked:
+		c.heldStatus = previous
+	}
+	c.Status = status
+	if len(c.statusHistory) == maxStatusHistory {
+		c.statusHistory = append(c.statusHistory[:0], c.statusHistory[1:]...)
+	}
+	c.statusHistory = append(c.statusHistory, StatusTransition{From: previous, To: status, Reason: reason, Time: time.Now()})
+	klog.V(1).Infof("Device status %s -> %s: %s", previous, status, reason)
+	return nil
+}
@@ -0,0 +1,114 @@
+package driver
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+func TestStatusTransitions(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+
+	require.NoError(t, client.Transition(common.StatusLocked, "maintenance"))
+	err := client.Transition(common.StatusExecucting, "execute")
+	assert.True(t, errors.Is(err, ErrIllegalTransition))
+	assert.Equal(t, common.StatusLocked, client.GetStatus())
+	_, _, ok := client.BeginExecution("open", 0, 0)
+	assert.False(t, ok)
+
+	require.NoError(t, client.Transition(common.StatusReady, "unlock"))
+	assert.False(t, client.CompareAndSetStatus(common.StatusSyncing, common.StatusReady, "sync over"))
+	require.NoError(t, client.Transition(common.StatusOffline, "port lost"))
+	assert.Error(t, client.Transition(common.StatusLocked, "lock"))
+	require.NoError(t, client.Transition(common.StatusReady, "port open"))
+
+	history := client.StatusHistory()
+	require.Len(t, history, 4)
+	assert.Equal(t, StatusTransition{From: common.StatusReady, To: common.StatusLocked, Reason: "maintenance", Time: history[0].Time}, history[0])
+	assert.Equal(t, common.StatusOffline, history[2].To)
+	assert.Equal(t, "port open", history[3].Reason)
+
+	for i := 0; i < maxStatusHistory; i++ {
+		require.NoError(t, client.Transition(common.StatusSyncing, "download"))
+		require.NoError(t, client.Transition(common.StatusReady, "download over"))
+	}
+	assert.Len(t, client.StatusHistory(), maxStatusHistory)
+}
+
+func TestLock(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+	client.Client.Config.ProtocolExtensions = true
+
+	assert.True(t, errors.Is(client.Unlock("done", "operator"), ErrNotLocked))
+	require.NoError(t, client.Lock("maintenance", "operator"))
+	assert.Equal(t, common.StatusLocked, client.GetStatus())
+	assert.False(t, client.AcceptsJobs())
+	_, err := client.Home("test")
+	assert.True(t, errors.Is(err, ErrHomingBusy))
+	history := client.StatusHistory()
+	assert.Equal(t, "locked by operator: maintenance", history[len(history)-1].Reason)
+	require.NoError(t, client.Unlock("done", "operator"))
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+
+	// A running execution can not be locked, a platform that failed to home
+	// is unlocked to Fault.
+	_, _, ok := client.BeginExecution("open", 0, 0)
+	require.True(t, ok)
+	assert.True(t, errors.Is(client.Lock("maintenance", "operator"), ErrIllegalTransition))
+	client.EndExecution()
+	client.homingError = ErrNotHomed
+	require.NoError(t, client.Transition(common.StatusFault, "homing failed"))
+	require.NoError(t, client.Lock("maintenance", "operator"))
+	require.NoError(t, client.Unlock("done", "operator"))
+	assert.Equal(t, common.StatusFault, client.GetStatus())
+}
+
+func TestCompareAndSetStatusRace(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won := 0
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if client.CompareAndSetStatus(common.StatusReady, common.StatusSyncing, "download") {
+				mu.Lock()
+				won++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, won)
+	assert.Equal(t, common.StatusSyncing, client.GetStatus())
+}
+
+func TestStatusSurvivesReconnect(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+
+	for _, held := range []common.DeviceStatus{common.StatusFault, common.StatusLocked} {
+		require.NoError(t, client.Transition(held, "test"))
+		client.sessionState(SessionDisconnected, nil)
+		assert.Equal(t, common.StatusOffline, client.GetStatus())
+		client.sessionState(SessionError, errors.New("no such port"))
+		client.sessionState(SessionConnected, nil)
+		assert.Equal(t, held, client.GetStatus())
+		require.NoError(t, client.Transition(common.StatusReady, "clear"))
+	}
+
+	// A Ready device is Ready again.
+	client.sessionState(SessionDisconnected, nil)
+	client.sessionState(SessionConnected, nil)
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+}
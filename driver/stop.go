@@ -0,0 +1,257 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// DefaultStopRamp is how long a stopped execution takes to bring the platform
+// back to zero when the device configures no stopRamp.
+const DefaultStopRamp = time.Second
+
+// ErrNotRunning is returned when stopping while nothing is executing.
+var ErrNotRunning = errors.New("no execution is running")
+
+// StopRecord tells why and by whom an execution was stopped.
+type StopRecord struct {
+	Reason string `json:"reason"`
+	By     string `json:"by"`
+	// Segment and Frame are where the execution was when it was stopped.
+	Segment   string        `json:"segment,omitempty"`
+	Frame     int           `json:"frame"`
+	Requested time.Time     `json:"requested"`
+	Ramp      time.Duration `json:"ramp"`
+}
+
+// execution is the running execution.
+type execution struct {
+	segment string
+	pass    int
+	frame   int
+	// frames and frequency are the length and rate of a segment playback,
+	// which control pauses, resumes and seeks. They are zero otherwise.
+	frames    int
+	frequency int
+	// indexes are the segment frames of the frames of the pass, see
+	// PlaybackOptions.Indexes. The pass plays the segment in order when nil.
+	indexes []int
+	control *PlaybackControl
+	cancel  context.CancelFunc
+	stop    *StopRecord
+}
+
+// BeginExecution moves a Ready device to Executing. The returned context is
+// cancelled by Stop; EndExecution must be called once the execution is over.
+// The playback of frames at frequency Hz is steered by the returned control,
+// which is nil when frames is zero.
+func (c *DigitalbowClient) BeginExecution(segment string, frames, frequency int) (context.Context, *PlaybackControl, bool) {
+	return c.beginExecution(segment, frames, frequency, nil)
+}
+
+// beginExecution is BeginExecution of a pass playing the segment frames
+// indexes.
+func (c *DigitalbowClient) beginExecution(segment string, frames, frequency int, indexes []int) (context.Context, *PlaybackControl, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Status != common.StatusReady || c.execution != nil || c.calibrating {
+		return nil, nil, false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &execution{segment: segment, cancel: cancel}
+	if frames > 0 {
+		if frequency <= 0 {
+			frequency = DefaultFrequency
+		}
+		run.frames, run.frequency, run.indexes, run.control = frames, frequency, indexes, NewPlaybackControl()
+	}
+	if err := c.setStatus(common.StatusExecucting, fmt.Sprintf("execute segment %q", segment)); err != nil {
+		cancel()
+		return nil, nil, false
+	}
+	c.execution = run
+	return ctx, run.control, true
+}
+
+// segmentFrame returns the segment frame played at frame of the pass.
+func (run *execution) segmentFrame(frame int) int {
+	if frame >= 0 && frame < len(run.indexes) {
+		return run.indexes[frame]
+	}
+	return frame
+}
+
+// passFrame returns the frame of the pass playing the segment frame, the first
+// one from the current frame on, and false when the pass does not play it.
+func (run *execution) passFrame(frame int) (int, bool) {
+	if run.indexes == nil {
+		return frame, frame >= 0 && frame < run.frames
+	}
+	for i := range run.indexes {
+		index := (run.frame + i) % len(run.indexes)
+		if run.indexes[index] == frame {
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// ExecutionProgress records the last frame of the pass sent by the execution.
+func (c *DigitalbowClient) ExecutionProgress(frame int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.execution != nil {
+		c.execution.frame = frame
+	}
+}
+
+// ExecutionPass records the pass the execution is playing.
+func (c *DigitalbowClient) ExecutionPass(pass int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.execution != nil {
+		c.execution.pass = pass
+	}
+}
+
+// EndExecution moves an Executing device back to Ready. A lost connection
+// leaves the device Offline rather than Ready.
+func (c *DigitalbowClient) EndExecution() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.execution == nil {
+		return
+	}
+	c.execution.cancel()
+	if c.Status == common.StatusExecucting || c.Status == common.StatusPaused {
+		_ = c.setStatus(common.StatusReady, fmt.Sprintf("execution of segment %q over", c.execution.segment))
+	}
+	c.execution = nil
+}
+
+// Stop cancels the running execution, which then ramps the platform back to
+// zero, and the jobs queued behind it so that the platform stays at rest.
+// Stopping an execution already stopping returns the first record.
+func (c *DigitalbowClient) Stop(reason, by string) (StopRecord, error) {
+	// The job queue is locked first, as runJobs does, so that no queued job
+	// starts between the stop and the cancellation of the queue.
+	q := &c.jobs
+	q.mu.Lock()
+	c.mu.Lock()
+	run := c.execution
+	if run == nil {
+		c.mu.Unlock()
+		q.mu.Unlock()
+		return StopRecord{}, ErrNotRunning
+	}
+	if run.stop != nil {
+		record := *run.stop
+		c.mu.Unlock()
+		q.mu.Unlock()
+		return record, nil
+	}
+	record := StopRecord{
+		Reason:    reason,
+		By:        by,
+		Segment:   run.segment,
+		Frame:     run.segmentFrame(run.frame),
+		Requested: time.Now(),
+		Ramp:      c.stopRamp(),
+	}
+	run.stop = &record
+	c.lastStop = &record
+	run.cancel()
+	c.mu.Unlock()
+	q.cancelQueued(&record)
+	q.mu.Unlock()
+
+	klog.Warningf("Execution of segment %q stopped at frame %d by %q: %s", record.Segment, record.Frame, by, reason)
+	if err := c.PublishData(common.DataLastStop, record); err != nil {
+		klog.Errorf("Publish stop record failed: %v", err)
+	}
+	return record, nil
+}
+
+// LastStop returns the record of the last stopped execution, nil if none was.
+func (c *DigitalbowClient) LastStop() *StopRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastStop == nil {
+		return nil
+	}
+	record := *c.lastStop
+	return &record
+}
+
+func (c *DigitalbowClient) stopRamp() time.Duration {
+	if c.Client.Config.StopRamp > 0 {
+		return c.Client.Config.StopRamp
+	}
+	return DefaultStopRamp
+}
+
+// RampToZero brings the platform from the pose from back to zero over the
+// stop ramp, at the playback rate or frequency Hz when the device has none.
+func (c *DigitalbowClient) RampToZero(from []float32, frequency int) error {
+	_, err := c.MoveTo(context.Background(), from, make([]float32, len(from)), c.stopRamp(), frequency)
+	return err
+}
+
+// MoveTo moves the platform from one pose to another over duration, at the
+// playback rate or frequency Hz when the device has none. The speed follows a
+// smoothstep so that the move starts and ends at rest. It returns the last pose
+// sent, where the platform is when the move fails or ctx is done.
+func (c *DigitalbowClient) MoveTo(ctx context.Context, from, to []float32, duration time.Duration, frequency int) ([]float32, error) {
+	if c.Client.Config.PlaybackRate > 0 {
+		frequency = c.Client.Config.PlaybackRate
+	}
+	playback := NewPlayback(frequency)
+	steps := int(math.Ceil(float64(duration) / float64(playback.Period)))
+	if steps < 1 {
+		steps = 1
+	}
+	pose := make([]float32, len(from))
+	reached := append([]float32(nil), from...)
+	clylen := make([]float32, kinematics.Legs)
+	_, err := playback.RunContext(ctx, steps, func(step int) error {
+		s := float32(step+1) / float32(steps)
+		s = s * s * (3 - 2*s)
+		for i := range from {
+			pose[i] = from[i] + (to[i]-from[i])*s
+		}
+		c.Client.Execute(pose, clylen)
+		frame, err := c.AssembleSerialData(clylen)
+		if err != nil {
+			return err
+		}
+		if err = c.Write(frame); err != nil {
+			return err
+		}
+		copy(reached, pose)
+		return nil
+	})
+	return reached, err
+}
@@ -0,0 +1,83 @@
+package driver
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+func TestStopExecution(t *testing.T) {
+	client := newTestClient(t)
+	client.Status = common.StatusReady
+	data := &recorder{values: map[string]string{}}
+	client.SetDataPublisher(data.publish)
+
+	_, err := client.Stop("collision", "operator")
+	assert.Equal(t, ErrNotRunning, err)
+
+	ctx, _, ok := client.BeginExecution("chewing", 100, 30)
+	require.True(t, ok)
+	assert.Equal(t, common.StatusExecucting, client.GetStatus())
+	_, _, ok = client.BeginExecution("chewing", 100, 30)
+	assert.False(t, ok)
+
+	client.ExecutionProgress(42)
+	record, err := client.Stop("collision", "operator")
+	require.NoError(t, err)
+	assert.Error(t, ctx.Err())
+	assert.Equal(t, "collision", record.Reason)
+	assert.Equal(t, "operator", record.By)
+	assert.Equal(t, "chewing", record.Segment)
+	assert.Equal(t, 42, record.Frame)
+	assert.Equal(t, DefaultStopRamp, record.Ramp)
+	assert.Contains(t, data.get(common.DataLastStop), `"reason":"collision"`)
+
+	// A second stop keeps the first record.
+	again, err := client.Stop("again", "someone else")
+	require.NoError(t, err)
+	assert.Equal(t, record, again)
+
+	client.EndExecution()
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+	assert.Equal(t, &record, client.LastStop())
+	_, err = client.Stop("late", "operator")
+	assert.Equal(t, ErrNotRunning, err)
+}
+
+func TestRampToZero(t *testing.T) {
+	client := newTestClient(t)
+	client.Client.Config.StopRamp = 50 * time.Millisecond
+	port := &fakePort{}
+	client.connect(func() (io.ReadWriteCloser, error) { return port, nil })
+	defer client.Close()
+	require.Eventually(t, client.Connected, time.Second, time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, client.RampToZero([]float32{0, 0, 0, 0, 0, 0.01}, 100))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(40*time.Millisecond))
+
+	port.mu.Lock()
+	written := port.written.Bytes()
+	port.mu.Unlock()
+	var heights []float32
+	for len(written) > 0 {
+		frame, n, err := protocol.Decode(written)
+		require.NoError(t, err)
+		written = written[n:]
+		positions, err := protocol.ParsePositions(frame.Payload)
+		require.NoError(t, err)
+		heights = append(heights, positions.Lengths()[0])
+	}
+	require.Len(t, heights, 5)
+	// The platform comes down without a jump, and ends at zero.
+	for i := 1; i < len(heights); i++ {
+		assert.Less(t, heights[i], heights[i-1])
+	}
+	assert.InDelta(t, protocol.StrokeZero, heights[len(heights)-1], 1e-4)
+}
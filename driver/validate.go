@@ -0,0 +1,219 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// rotationTolerance is how far a track rotation may be from orthonormal.
+const rotationTolerance = 1e-3
+
+// ErrInvalidTrack is returned when a track fails validation.
+var ErrInvalidTrack = errors.New("invalid track")
+
+// TrackIssue is one problem found in a track. Frame is -1 for problems of the
+// whole track.
+type TrackIssue struct {
+	Frame  int    `json:"frame"`
+	Reason string `json:"reason"`
+}
+
+// TrackValidation is the result of validating a track.
+type TrackValidation struct {
+	Segment string       `json:"segment"`
+	Frames  int          `json:"frames"`
+	Issues  []TrackIssue `json:"issues,omitempty"`
+	// Repaired lists the frames whose rotation was re-orthonormalised.
+	Repaired []int `json:"repaired,omitempty"`
+}
+
+// Valid reports whether the track has no remaining issue.
+func (v TrackValidation) Valid() bool {
+	return len(v.Issues) == 0
+}
+
+// ValidateTrack checks that the list sizes agree and that every frame of the
+// Matrix_list, composed under mode as SegmentPoses does, is a finite rigid
+// transform. With orthonormalize, frames whose only problem is a drifted
+// rotation are repaired so that their composed rotation is the nearest one.
+func ValidateTrack(track *TrackData, mode string, orthonormalize bool) TrackValidation {
+	validation := TrackValidation{Frames: len(track.MatrixList)}
+	addIssue := func(frame int, format string, args ...interface{}) {
+		validation.Issues = append(validation.Issues, TrackIssue{Frame: frame, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if len(track.MatrixList) == 0 {
+		addIssue(-1, "Matrix_list is empty")
+	}
+	if track.Size != len(track.MatrixList) {
+		addIssue(-1, "size %d does not match %d Matrix_list frames", track.Size, len(track.MatrixList))
+	}
+	names := []string{"IP_list", "LC_list", "RC_list"}
+	for i, list := range [][][3]float64{track.IPList, track.LCList, track.RCList} {
+		if len(list) != 0 && len(list) != len(track.MatrixList) {
+			addIssue(-1, "%s has %d points for %d frames", names[i], len(list), len(track.MatrixList))
+		}
+	}
+	if track.Frequency < 0 {
+		addIssue(-1, "negative frequency %d", track.Frequency)
+	}
+	if !finiteMatrix(track.MatrixInit) {
+		addIssue(-1, "Matrix_init is not finite")
+	}
+	if err := ValidateComposition(track.Composition); err != nil {
+		addIssue(-1, err.Error())
+	}
+
+	additive := mode == "" || mode == CompositionAdditive
+	reference := track.MatrixInit
+	if mode == CompositionRelative && len(track.MatrixList) != 0 {
+		reference = track.MatrixList[0]
+	}
+	for frame := range track.MatrixList {
+		m := &track.MatrixList[frame]
+		if !finiteMatrix(*m) {
+			addIssue(frame, "matrix is not finite")
+			continue
+		}
+		composed := composedFrame(mode, *m, reference)
+		if !finalRow(composed[3]) {
+			addIssue(frame, "final row is %v, expect [0 0 0 1]", composed[3])
+			continue
+		}
+		reason := rotationIssue(composed)
+		if reason == "" {
+			continue
+		}
+		if orthonormalize && repairRotation(m, additive, mode, reference) {
+			validation.Repaired = append(validation.Repaired, frame)
+			continue
+		}
+		addIssue(frame, reason)
+	}
+	return validation
+}
+
+// composedFrame returns the transform of frame under mode as an array.
+func composedFrame(mode string, frame, reference [4][4]float64) [4][4]float64 {
+	composed := ComposeFrame(mode, frame, reference)
+	var m [4][4]float64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			m[i][j] = composed.At(i, j)
+		}
+	}
+	return m
+}
+
+// repairRotation replaces the rotation of frame so that its composed rotation
+// is the nearest proper rotation, and reports whether it is then one.
+func repairRotation(frame *[4][4]float64, additive bool, mode string, reference [4][4]float64) bool {
+	if additive {
+		// The rotation of an additive frame is a delta from the reference.
+		composed := composedFrame(mode, *frame, reference)
+		if err := nearestRotation(&composed); err != nil {
+			return false
+		}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				frame[i][j] = composed[i][j] - reference[i][j]
+			}
+		}
+		return true
+	}
+	repaired := *frame
+	if err := nearestRotation(&repaired); err != nil {
+		return false
+	}
+	if rotationIssue(composedFrame(mode, repaired, reference)) != "" {
+		return false
+	}
+	*frame = repaired
+	return true
+}
+
+func finalRow(row [4]float64) bool {
+	expected := [4]float64{0, 0, 0, 1}
+	for i := range row {
+		if math.Abs(row[i]-expected[i]) > rotationTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func finiteMatrix(m [4][4]float64) bool {
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			if math.IsNaN(m[i][j]) || math.IsInf(m[i][j], 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// rotationIssue returns why the upper left 3x3 block of m is not a rotation,
+// or "" if it is one.
+func rotationIssue(m [4][4]float64) string {
+	r := rotationBlock(m)
+	var product mat.Dense
+	product.Mul(r.T(), r)
+	if !mat.EqualApprox(&product, identity(3), rotationTolerance) {
+		return "rotation is not orthonormal"
+	}
+	if det := mat.Det(r); math.Abs(det-1) > rotationTolerance {
+		return fmt.Sprintf("rotation determinant is %.6f, expect 1", det)
+	}
+	return ""
+}
+
+// nearestRotation replaces the rotation of m by the closest proper rotation
+// in the Frobenius norm, R = U Vᵀ of its singular value decomposition.
+func nearestRotation(m *[4][4]float64) error {
+	var svd mat.SVD
+	if ok := svd.Factorize(rotationBlock(*m), mat.SVDFull); !ok {
+		return errors.New("singular value decomposition failed")
+	}
+	var u, v, r mat.Dense
+	svd.UTo(&u)
+	svd.VTo(&v)
+	r.Mul(&u, v.T())
+	if mat.Det(&r) < 0 {
+		// A reflection is not a drifted rotation.
+		return errors.New("rotation is a reflection")
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			m[i][j] = r.At(i, j)
+		}
+	}
+	return nil
+}
+
+func rotationBlock(m [4][4]float64) *mat.Dense {
+	r := mat.NewDense(3, 3, nil)
+	for i := 0; i < 3; i++ {
+		r.SetRow(i, m[i][:3])
+	}
+	return r
+}
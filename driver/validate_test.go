@@ -0,0 +1,67 @@
+package driver
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validTrack() TrackData {
+	return TrackData{
+		Size:       3,
+		Frequency:  30,
+		MatrixList: [][4][4]float64{transformZ(0, 0), transformZ(10, 1), transformZ(20, 2)},
+		MatrixInit: transformZ(0, 0),
+		IPList:     [][3]float64{{0, 0, 0}, {1, 1, 1}, {2, 2, 2}},
+	}
+}
+
+func TestValidateTrack(t *testing.T) {
+	track := validTrack()
+	assert.True(t, ValidateTrack(&track, CompositionPremultiply, false).Valid())
+
+	track = validTrack()
+	track.Size = 4
+	track.LCList = [][3]float64{{0, 0, 0}}
+	validation := ValidateTrack(&track, CompositionPremultiply, false)
+	assert.Len(t, validation.Issues, 2)
+	assert.Equal(t, -1, validation.Issues[0].Frame)
+
+	track = validTrack()
+	track.MatrixList[1][0][0] = math.NaN()
+	track.MatrixList[2][3] = [4]float64{0, 0, 1, 1}
+	validation = ValidateTrack(&track, CompositionPremultiply, true)
+	assert.Equal(t, []TrackIssue{
+		{Frame: 1, Reason: "matrix is not finite"},
+		{Frame: 2, Reason: "final row is [0 0 1 1], expect [0 0 0 1]"},
+	}, validation.Issues)
+
+	track = validTrack()
+	track.Composition = "multiply"
+	assert.Equal(t, []TrackIssue{{Frame: -1, Reason: "unknown composition mode multiply"}},
+		ValidateTrack(&track, CompositionPremultiply, false).Issues)
+}
+
+func TestValidateTrackOrthonormalize(t *testing.T) {
+	track := validTrack()
+	track.MatrixList[1][0][0] *= 1.01
+	track.MatrixList[2][2][2] = -1
+
+	validation := ValidateTrack(&track, CompositionPremultiply, false)
+	assert.Equal(t, []int{1, 2}, []int{validation.Issues[0].Frame, validation.Issues[1].Frame})
+	assert.Empty(t, validation.Repaired)
+
+	validation = ValidateTrack(&track, CompositionPremultiply, true)
+	// A reflection can not be repaired.
+	assert.Len(t, validation.Issues, 1)
+	assert.Equal(t, 2, validation.Issues[0].Frame)
+	assert.Equal(t, []int{1}, validation.Repaired)
+	assert.Empty(t, rotationIssue(track.MatrixList[1]))
+	expected := transformZ(10, 1)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			assert.InDelta(t, expected[i][j], track.MatrixList[1][i][j], 0.01)
+		}
+	}
+}
@@ -74,6 +74,7 @@ function build() {
   local arch=${os_arch[1]}
   CC=arm-linux-gnueabihf-gcc GOOS=${os} GOARCH=${arch} CGO_ENABLED=1 \
   GOGCCFLAGS="-fPIC -pthread -mfpu=vfp -mfloat-abi=hard --static" go build \
+    -tags "${TAGS-sixdof}" \
     -ldflags "${flags} ${ext_flags}" \
     -o "${CURR_DIR}/bin/${mapper}_${os}_${arch}" \
     "${CURR_DIR}/cmd/main.go"
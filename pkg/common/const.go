@@ -8,6 +8,35 @@ const (
 	TopicStateUpdate     = "$hw/events/device/%s/state/update"
 	TopicDataUpdate      = "$ke/events/device/%s/data/update"
 	TopicDeviceUpdate    = "$hw/events/node/#"
+	// TopicCommand receives the commands of a device, joint with the device ID
+	// and the command name.
+	TopicCommand = "$ke/events/device/%s/command/%s"
+)
+
+// Device commands received on TopicCommand.
+const (
+	CommandStop   = "stop"
+	CommandPause  = "pause"
+	CommandResume = "resume"
+	CommandSeek   = "seek"
+	CommandHome   = "home"
+	CommandLock   = "lock"
+	CommandUnlock = "unlock"
+)
+
+// Device data properties published by the mapper.
+const (
+	DataPathAnalysis      = "path-analysis"
+	DataCylinderPositions = "cylinder-positions"
+	DataLastStop          = "last-stop"
+	DataExecution         = "execution"
+	DataHoming            = "homing"
+)
+
+// Device twin properties reported by the mapper.
+const (
+	TwinControllerFault = "controller-fault"
+	TwinDeviceStatus    = "device-status"
 )
 
 // Device status definition.
@@ -34,6 +63,35 @@ const (
 	APIDeviceDownload = APIBase + "/download"
 	// APIDeviceCallbackIDRoute to build update device's RESTful API
 	APIDeviceExecute = APIBase + "/execute"
+	// APIDeviceStop to stop the running execution
+	APIDeviceStop = APIBase + "/stop"
+	// APIDevicePause, APIDeviceResume and APIDeviceSeek to steer the running
+	// segment playback
+	APIDevicePause  = APIBase + "/pause"
+	APIDeviceResume = APIBase + "/resume"
+	APIDeviceSeek   = APIBase + "/seek"
+	// APIDeviceHome to home the platform or report the last homing
+	APIDeviceHome = APIBase + "/home"
+	// APIDeviceLock and APIDeviceUnlock to lock the device against any motion
+	// and to unlock it
+	APIDeviceLock   = APIBase + "/lock"
+	APIDeviceUnlock = APIBase + "/unlock"
+	// APIDeviceStatus to report the device status and the execution progress
+	APIDeviceStatus = APIBase + "/status"
+	// APIDeviceStatusHistory to report the last device status transitions
+	APIDeviceStatusHistory = APIDeviceStatus + "/history"
+	// APIDeviceJobs to list the execution jobs, APIDeviceJob to query or
+	// cancel one
+	APIDeviceJobs = APIBase + "/jobs"
+	APIDeviceJob  = APIDeviceJobs + "/{" + ID + "}"
+	// APIDeviceFrame to report the tracker to bow frame transform
+	APIDeviceFrame = APIBase + "/frame"
+	// APIDeviceCalibrateFrame to calibrate the frame transform from reference points
+	APIDeviceCalibrateFrame = APIBase + "/calibrate/frame"
+	// APIDeviceCalibrateCylinders to report or calibrate the cylinder encoders
+	APIDeviceCalibrateCylinders = APIBase + "/calibrate/cylinders"
+	// APIDeviceAnalysis to analyse the incisal and condylar paths of a segment
+	APIDeviceAnalysis = APIBase + "/analysis/{" + Segment + "}"
 
 	// APIPingRoute to build ping command's RESTful API
 	APIPingRoute = APIBase + "/ping"
@@ -46,6 +104,8 @@ const (
 	Command = "command"
 	// IDAndCommand to build RESTful API
 	IDAndCommand = "IdAndCommand"
+	// Segment to build RESTful API
+	Segment = "segment"
 )
 
 // Constants related to the possible content types supported by the APIs
@@ -70,6 +130,7 @@ const (
 	KindRangeNotSatisfiable ErrKind = "RangeNotSatisfiable"
 	KindOverflowError       ErrKind = "OverflowError"
 	KindNaNError            ErrKind = "NaNError"
+	KindContractInvalid     ErrKind = "ContractInvalid"
 )
 
 type DeviceStatus string
@@ -78,4 +139,15 @@ const (
 	StatusReady      DeviceStatus = "Ready"
 	StatusSyncing    DeviceStatus = "Syncing"
 	StatusExecucting DeviceStatus = "Executing"
+	StatusPaused     DeviceStatus = "Paused"
+	// StatusOffline means the serial port is not open yet or was lost.
+	StatusOffline DeviceStatus = "Offline"
+	// StatusBroken means the serial port can not be opened.
+	StatusBroken DeviceStatus = "Broken"
+	// StatusFault means the controller reports a fault.
+	StatusFault DeviceStatus = "Fault"
+	// StatusHoming means the platform is moving to its home position.
+	StatusHoming DeviceStatus = "Homing"
+	// StatusLocked means an operator locked the device against any motion.
+	StatusLocked DeviceStatus = "Locked"
 )
@@ -1,15 +1,19 @@
 package httpadapter
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/jacobsa/go-serial/serial"
+	"github.com/gorilla/mux"
 	"github.com/smilelinkd/digitalbow-mapper/configmap"
+	"github.com/smilelinkd/digitalbow-mapper/driver"
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
 	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
-	"gonum.org/v1/gonum/mat"
 	"k8s.io/klog/v2"
 )
 
@@ -21,10 +25,6 @@ func (c *RestController) Ping(writer http.ResponseWriter, request *http.Request)
 }
 
 func (c *RestController) Download(writer http.ResponseWriter, request *http.Request) {
-	if c.Client.GetStatus() != common.StatusReady {
-		c.sendMapperError(writer, request, "For now device is not ready please try next time!", common.APIDeviceDownload)
-		return
-	}
 	response := "This is API " + common.APIVersion + ". Now is " + time.Now().Format(time.UnixDate)
 	var downResultRequest configmap.DownloadRequest
 	err := json.NewDecoder(request.Body).Decode(&downResultRequest)
@@ -33,25 +33,50 @@ func (c *RestController) Download(writer http.ResponseWriter, request *http.Requ
 		c.sendMapperError(writer, request, err.Error(), common.APIDeviceDownload)
 		return
 	}
-	c.Client.SetStatus(common.StatusSyncing)
-	err = c.Client.DownloadResult(downResultRequest.Path, downResultRequest.Segment)
+	reason := fmt.Sprintf("download segment %q", downResultRequest.Segment)
+	if !c.Client.CompareAndSetStatus(common.StatusReady, common.StatusSyncing, reason) {
+		c.sendMapperError(writer, request, "For now device is not ready please try next time!", common.APIDeviceDownload)
+		return
+	}
+	validation, err := c.Client.DownloadResult(downResultRequest.Path, downResultRequest.Segment,
+		downResultRequest.Orthonormalize, downResultRequest.Composition)
+	// A fault or a lost port while syncing is kept.
+	c.Client.CompareAndSetStatus(common.StatusSyncing, common.StatusReady, reason+" over")
+	if errors.Is(err, driver.ErrInvalidTrack) {
+		c.sendMapperReport(writer, request, validation, common.KindRangeNotSatisfiable, common.APIDeviceDownload,
+			"segment %s is invalid: %v", downResultRequest.Segment, validation.Issues)
+		return
+	}
 	if err != nil {
 		klog.Error("Can't download file into memory: ", err)
 		c.sendMapperError(writer, request, err.Error(), common.APIDeviceDownload)
-		c.Client.SetStatus(common.StatusReady)
 		return
 	}
-	c.Client.SetStatus(common.StatusReady)
+	if analysis, err := c.Client.AnalyzeSegment(downResultRequest.Segment); err != nil {
+		klog.V(2).Infof("Segment %s has no path analysis: %v", downResultRequest.Segment, err)
+	} else if err = c.Client.PublishData(common.DataPathAnalysis, analysis); err != nil {
+		klog.Errorf("Publish path analysis failed: %v", err)
+	}
 	c.sendResponse(writer, request, common.APIDeviceDownload, response, http.StatusOK)
 }
 
+// randomPoses returns the two poses a random execution sends: the input pose
+// then zero, or two test poses without input.
+func (c *RestController) randomPoses(input []float32) [][]float32 {
+	if len(input) != 0 {
+		return [][]float32{input, make([]float32, kinematics.Legs)}
+	}
+	return [][]float32{c.Client.RandomGetCylen(1), c.Client.RandomGetCylen(2)}
+}
+
+// Execute queues an execution job and returns it. The job starts once the
+// jobs queued before it are over and the device is Ready.
 func (c *RestController) Execute(writer http.ResponseWriter, request *http.Request) {
-	if c.Client.GetStatus() != common.StatusReady {
+	if !c.Client.AcceptsJobs() {
 		c.sendMapperError(writer, request, "For now device is not ready please try next time!", common.APIDeviceExecute)
 		return
 	}
 
-	response := "This is API " + common.APIVersion + ". Now is " + time.Now().Format(time.UnixDate)
 	var executeRequest configmap.ExecuteRequest
 	err := json.NewDecoder(request.Body).Decode(&executeRequest)
 	if err != nil {
@@ -59,97 +84,415 @@ func (c *RestController) Execute(writer http.ResponseWriter, request *http.Reque
 		c.sendMapperError(writer, request, err.Error(), common.APIDeviceExecute)
 		return
 	}
+	if n := len(executeRequest.Input); n != 0 && n != kinematics.Legs {
+		c.sendMapperReport(writer, request, "input is roll, pitch, yaw, x, y, z", common.KindContractInvalid,
+			common.APIDeviceExecute, "input has %d values, expect %d", n, kinematics.Legs)
+		return
+	}
 
-	if _, ok := c.Client.Movements[executeRequest.Segment]; !ok && !executeRequest.Random {
+	if _, ok := c.Client.Movement(executeRequest.Segment); !ok && !executeRequest.Random {
 		c.sendMapperError(writer, request, "The segment does not exist, please download first!", common.APIDeviceExecute)
 		return
 	}
 
-	c.Client.SetStatus(common.StatusExecucting)
-
-	options := serial.OpenOptions{
-		PortName:        c.Client.Client.Config.SerialName,
-		BaudRate:        uint(c.Client.Client.Config.BaudRate),
-		DataBits:        uint(c.Client.Client.Config.DataBits),
-		StopBits:        uint(c.Client.Client.Config.StopBits),
-		ParityMode:      serial.PARITY_NONE,
-		MinimumReadSize: 4,
+	var trackData driver.TrackData
+	var poses [][]float32
+	var indexes []int
+	var profileReport driver.ProfileReport
+	options := driver.PlaybackOptions{
+		Speed:      executeRequest.Speed,
+		Loops:      executeRequest.Loops,
+		Continuous: executeRequest.Continuous,
+		PingPong:   executeRequest.PingPong,
+		Reverse:    executeRequest.Reverse,
+		Start:      executeRequest.Start,
+		End:        executeRequest.End,
 	}
-
-	go func() {
-		port, err := serial.Open(options)
+	if !executeRequest.Random {
+		trackData, err = c.Client.PlaybackTrack(executeRequest.Segment, executeRequest.Rate)
 		if err != nil {
-			klog.V(2).Infof("Error opening serial port... %v", err)
+			c.sendMapperError(writer, request, err.Error(), common.APIDeviceExecute)
 			return
 		}
-		defer port.Close()
-		defer c.Client.SetStatus(common.StatusReady)
+		// poses is one pass, repeated by the playback when looping, playing
+		// the segment frames indexes.
+		segment := c.Client.SegmentPoses(trackData)
+		if indexes, err = options.Indexes(len(segment)); err == nil {
+			poses, err = options.Pass(segment)
+		}
+		if err != nil {
+			c.sendMapperReport(writer, request, err.Error(), common.KindContractInvalid, common.APIDeviceExecute,
+				"segment %s playback options refused: %v", executeRequest.Segment, err)
+			return
+		}
+		limits := c.Client.Client.Config.MotionLimits
+		poses, profileReport, err = driver.ProfileMotion(poses, trackData.Frequency, limits)
+		if err != nil {
+			c.sendMapperError(writer, request, err.Error(), common.APIDeviceExecute)
+			return
+		}
+		if profileReport.ModifiedFrames != 0 || profileReport.TimeScale != 1 {
+			klog.V(1).Infof("Segment %s motion profile %v", executeRequest.Segment, profileReport)
+		}
+		frequency := trackData.Frequency
+		if frequency <= 0 {
+			frequency = driver.DefaultFrequency
+		}
+		if err = options.CheckMotion(poses, float64(frequency)/profileReport.TimeScale, limits); err != nil {
+			c.sendMapperReport(writer, request, err.Error(), common.KindRangeNotSatisfiable, common.APIDeviceExecute,
+				"segment %s playback refused: %v", executeRequest.Segment, err)
+			return
+		}
+	} else {
+		poses = c.randomPoses(executeRequest.Input)
+	}
+	if report := c.Client.CheckLimits(executeRequest.Segment, poses); len(report.Violations) != 0 {
+		c.sendMapperReport(writer, request, report, common.KindRangeNotSatisfiable, common.APIDeviceExecute,
+			"segment %s exceeds the platform limits at frames %v", executeRequest.Segment, report.FrameIndexes())
+		return
+	}
+
+	if !c.Client.Connected() {
+		c.sendMapperError(writer, request, driver.ErrDisconnected.Error(), common.APIDeviceExecute)
+		return
+	}
+	// Only a segment playback can be paused and sought.
+	frames := 0
+	if !executeRequest.Random {
+		frames = len(poses)
+	}
 
+	run := func(ctx context.Context, control *driver.PlaybackControl) error {
+		// last is the pose sent last, where a stop ramps down from.
+		last := make([]float32, 6)
 		if !executeRequest.Random {
-			trackData := c.Client.Movements[executeRequest.Segment]
-			aInit := make([]float64, 6)
 			clylen := make([]float32, 6)
-			for record, item := range trackData.MatrixList {
-				bowResult := c.Client.GetBowDataformat(item, trackData.MatrixInit)
-				if record == 0 {
-					//# 记录第0帧的初始参数
-					aInit = bowResult
+			playback := driver.NewPlayback(trackData.Frequency)
+			playback.Scale(profileReport.TimeScale / options.SpeedFactor())
+			playback.Control = control
+			playback.Transition = func(from, to int) error {
+				reached, err := c.Client.MoveTo(ctx, last, poses[to], c.Client.SeekTransition(), trackData.Frequency)
+				copy(last, reached)
+				if err == nil {
+					c.Client.ExecutionProgress(to)
 				}
-				matrixA := mat.NewDense(1, 6, bowResult)
-				matrixInit := mat.NewDense(1, 6, aInit)
-				var sixdofA mat.Dense
-				sixdofA.Sub(matrixA, matrixInit)
-				input64 := sixdofA.RawRowView(0)
-				input32 := make([]float32, 6)
-				for i := 0; i < 6; i++ {
-					input32[i] = float32(input64[i])
+				return err
+			}
+			send := func(record int) error {
+				// A pause may race with the frame already due.
+				if status := c.Client.GetStatus(); status != common.StatusExecucting && status != common.StatusPaused {
+					return fmt.Errorf("device is %s", status)
 				}
+				input32 := poses[record]
 				c.Client.Client.Execute(input32, clylen)
-				time.Sleep(33 * time.Millisecond)
 				klog.V(2).Infof("execute with %v", clylen)
-				_, err := port.Write(c.Client.AssembleSerialData(clylen))
+				if err := c.Client.CheckFrame(input32, clylen); err != nil {
+					klog.Warningf("Frame %d failed the forward kinematics check: %v", record, err)
+				}
+				writeMessage, err := c.Client.AssembleSerialData(clylen)
 				if err != nil {
-					klog.Errorf("Error writing to serial port:%v ", err)
-					return
+					return err
+				}
+				if err = c.Client.Write(writeMessage); err != nil {
+					return err
 				}
+				copy(last, input32)
+				c.Client.ExecutionProgress(record)
+				return nil
+			}
+			err := c.Client.PlayPasses(ctx, playback, options, poses, send)
+			if errors.Is(err, context.Canceled) {
+				c.rampToZero(last, trackData.Frequency)
+				return err
+			}
+			if err != nil {
+				return fmt.Errorf("segment %s playback: %v", executeRequest.Segment, err)
 			}
 		} else {
 			clylen := make([]float32, 6)
 			for i := 1; i <= 2; i++ {
-				var bowResult []float32
-				if len(executeRequest.Input) != 0 {
-					if i%2 == 0 {
-						bowResult = []float32{0, 0, 0, 0, 0, 0}
-					} else {
-						bowResult = executeRequest.Input
-					}
-				} else {
-					bowResult = c.Client.RandomGetCylen(i)
-				}
+				bowResult := poses[i-1]
 				c.Client.Client.Execute(bowResult, clylen)
 				klog.V(2).Infof("execute output %v", clylen)
-				writeMessage := c.Client.AssembleSerialData(clylen)
+				writeMessage, err := c.Client.AssembleSerialData(clylen)
+				if err != nil {
+					return fmt.Errorf("encode frame: %v", err)
+				}
 				hex_string_data := hex.EncodeToString(writeMessage)
 				klog.V(2).Infof("serial output %s", hex_string_data)
-				_, err := port.Write(writeMessage)
+				err = c.Client.Write(writeMessage)
 				if err != nil {
-					klog.Errorf("Error writing to serial port:%v ", err)
-					return
+					return fmt.Errorf("write to serial port: %v", err)
 				}
+				copy(last, bowResult)
+				c.Client.ExecutionProgress(i - 1)
+				period := 2 * time.Second
 				if executeRequest.Period != 0 {
-					time.Sleep(time.Duration(executeRequest.Period) * time.Second)
-				} else {
-					time.Sleep(2 * time.Second)
+					period = time.Duration(executeRequest.Period) * time.Second
+				}
+				select {
+				case <-ctx.Done():
+					c.rampToZero(last, driver.DefaultFrequency)
+					return ctx.Err()
+				case <-time.After(period):
 				}
 			}
 		}
 		// reset..
-		_, err = port.Write(c.Client.ResetToZero())
+		writeMessage, err := c.Client.ResetToZero()
+		if err == nil {
+			err = c.Client.Write(writeMessage)
+		}
 		if err != nil {
-			klog.Errorf("Error writing to serial port:%v ", err)
+			return fmt.Errorf("write to serial port: %v", err)
+		}
+		return nil
+	}
+
+	job, err := c.Client.SubmitJob(driver.JobSpec{
+		Segment:   executeRequest.Segment,
+		Frames:    frames,
+		Frequency: trackData.Frequency,
+		Indexes:   indexes,
+		Run:       run,
+	})
+	if err != nil {
+		c.sendMapperError(writer, request, err.Error(), common.APIDeviceExecute)
+		return
+	}
+	c.sendResponse(writer, request, common.APIDeviceExecute, job, http.StatusAccepted)
+}
+
+// rampToZero brings a stopped execution back to zero.
+func (c *RestController) rampToZero(from []float32, frequency int) {
+	if err := c.Client.RampToZero(from, frequency); err != nil {
+		klog.Errorf("Ramp to zero after stop failed: %v", err)
+	}
+}
+
+// Stop cancels the running execution, recording why and by whom.
+func (c *RestController) Stop(writer http.ResponseWriter, request *http.Request) {
+	var stopRequest configmap.StopRequest
+	if request.ContentLength != 0 {
+		if err := json.NewDecoder(request.Body).Decode(&stopRequest); err != nil {
+			klog.Error("Bad request, failed to decode JSON: ", err)
+			c.sendMapperError(writer, request, err.Error(), common.APIDeviceStop)
 			return
 		}
-	}()
+	}
+	if stopRequest.By == "" {
+		stopRequest.By = request.RemoteAddr
+	}
+	record, err := c.Client.Stop(stopRequest.Reason, stopRequest.By)
+	if errors.Is(err, driver.ErrNotRunning) {
+		c.sendMapperReport(writer, request, err.Error(), common.KindNotAllowed, common.APIDeviceStop, "stop refused: %v", err)
+		return
+	}
+	if err != nil {
+		c.sendMapperError(writer, request, err.Error(), common.APIDeviceStop)
+		return
+	}
+	c.sendResponse(writer, request, common.APIDeviceStop, record, http.StatusOK)
+}
+
+// Pause holds the running segment playback at its current pose.
+func (c *RestController) Pause(writer http.ResponseWriter, request *http.Request) {
+	c.sendControlResult(writer, request, common.APIDevicePause, c.Client.Pause())
+}
+
+// Resume continues the paused segment playback.
+func (c *RestController) Resume(writer http.ResponseWriter, request *http.Request) {
+	c.sendControlResult(writer, request, common.APIDeviceResume, c.Client.Resume())
+}
 
-	c.sendResponse(writer, request, common.APIDeviceExecute, response, http.StatusOK)
+// Seek moves the running segment playback to a frame or a track time.
+func (c *RestController) Seek(writer http.ResponseWriter, request *http.Request) {
+	var seekRequest configmap.SeekRequest
+	if err := json.NewDecoder(request.Body).Decode(&seekRequest); err != nil {
+		klog.Error("Bad request, failed to decode JSON: ", err)
+		c.sendMapperError(writer, request, err.Error(), common.APIDeviceSeek)
+		return
+	}
+	err := c.Client.SeekFrameOrTime(seekRequest.Frame, seekRequest.Time)
+	c.sendControlResult(writer, request, common.APIDeviceSeek, err)
+}
+
+// sendControlResult answers a pause, resume or seek with the execution status.
+func (c *RestController) sendControlResult(writer http.ResponseWriter, request *http.Request, API string, err error) {
+	switch {
+	case errors.Is(err, driver.ErrSeekRange):
+		c.sendMapperReport(writer, request, err.Error(), common.KindRangeNotSatisfiable, API, "%v", err)
+	case err != nil:
+		c.sendMapperReport(writer, request, err.Error(), common.KindNotAllowed, API, "%v", err)
+	default:
+		c.sendResponse(writer, request, API, c.Client.ExecutionStatus(), http.StatusOK)
+	}
+}
+
+// Lock locks the device against any motion, recording why and by whom.
+func (c *RestController) Lock(writer http.ResponseWriter, request *http.Request) {
+	c.sendLockResult(writer, request, common.APIDeviceLock, c.Client.Lock)
+}
+
+// Unlock unlocks the locked device, recording why and by whom.
+func (c *RestController) Unlock(writer http.ResponseWriter, request *http.Request) {
+	c.sendLockResult(writer, request, common.APIDeviceUnlock, c.Client.Unlock)
+}
+
+// sendLockResult decodes a lock request, applies it with change and answers
+// with the device status.
+func (c *RestController) sendLockResult(writer http.ResponseWriter, request *http.Request, API string,
+	change func(reason, by string) error) {
+	var lockRequest configmap.LockRequest
+	if request.ContentLength != 0 {
+		if err := json.NewDecoder(request.Body).Decode(&lockRequest); err != nil {
+			klog.Error("Bad request, failed to decode JSON: ", err)
+			c.sendMapperError(writer, request, err.Error(), API)
+			return
+		}
+	}
+	if lockRequest.By == "" {
+		lockRequest.By = request.RemoteAddr
+	}
+	if err := change(lockRequest.Reason, lockRequest.By); err != nil {
+		c.sendMapperReport(writer, request, err.Error(), common.KindNotAllowed, API, "%v", err)
+		return
+	}
+	c.sendResponse(writer, request, API, c.Client.ExecutionStatus(), http.StatusOK)
+}
+
+// Status reports the device status and the progress of the running execution.
+func (c *RestController) Status(writer http.ResponseWriter, request *http.Request) {
+	c.sendResponse(writer, request, common.APIDeviceStatus, c.Client.ExecutionStatus(), http.StatusOK)
+}
+
+// Home runs the homing sequence and reports how it went.
+func (c *RestController) Home(writer http.ResponseWriter, request *http.Request) {
+	report, err := c.Client.Home("home requested over HTTP")
+	switch {
+	case errors.Is(err, driver.ErrHomingBusy), errors.Is(err, driver.ErrExtension):
+		c.sendMapperReport(writer, request, c.Client.ExecutionStatus(), common.KindNotAllowed, common.APIDeviceHome, "%v", err)
+	case errors.Is(err, driver.ErrHomingUnsupported):
+		c.sendMapperReport(writer, request, report, common.KindNotAllowed, common.APIDeviceHome, "%v", err)
+	case err != nil:
+		c.sendMapperReport(writer, request, report, common.KindServerError, common.APIDeviceHome, "homing failed: %v", err)
+	default:
+		c.sendResponse(writer, request, common.APIDeviceHome, report, http.StatusOK)
+	}
+}
+
+// LastHoming reports the last homing sequence.
+func (c *RestController) LastHoming(writer http.ResponseWriter, request *http.Request) {
+	report := c.Client.LastHoming()
+	if report == nil {
+		c.sendMapperReport(writer, request, "the platform was never homed", common.KindEntityDoesNotExist,
+			common.APIDeviceHome, "no homing report")
+		return
+	}
+	c.sendResponse(writer, request, common.APIDeviceHome, report, http.StatusOK)
+}
+
+// StatusHistory reports the last device status transitions.
+func (c *RestController) StatusHistory(writer http.ResponseWriter, request *http.Request) {
+	c.sendResponse(writer, request, common.APIDeviceStatusHistory, c.Client.StatusHistory(), http.StatusOK)
+}
+
+// Jobs lists the queued, running and recently finished execution jobs.
+func (c *RestController) Jobs(writer http.ResponseWriter, request *http.Request) {
+	c.sendResponse(writer, request, common.APIDeviceJobs, c.Client.Jobs(), http.StatusOK)
+}
+
+// Job reports the state and progress of an execution job.
+func (c *RestController) Job(writer http.ResponseWriter, request *http.Request) {
+	job, err := c.Client.Job(mux.Vars(request)[common.ID])
+	if err != nil {
+		c.sendMapperReport(writer, request, err.Error(), common.KindEntityDoesNotExist, common.APIDeviceJob, "%v", err)
+		return
+	}
+	c.sendResponse(writer, request, common.APIDeviceJob, job, http.StatusOK)
+}
+
+// CancelJob removes a queued execution job from the queue.
+func (c *RestController) CancelJob(writer http.ResponseWriter, request *http.Request) {
+	job, err := c.Client.CancelJob(mux.Vars(request)[common.ID])
+	switch {
+	case errors.Is(err, driver.ErrJobNotFound):
+		c.sendMapperReport(writer, request, err.Error(), common.KindEntityDoesNotExist, common.APIDeviceJob, "%v", err)
+	case err != nil:
+		c.sendMapperReport(writer, request, job, common.KindNotAllowed, common.APIDeviceJob, "cancel job %s: %v", job.ID, err)
+	default:
+		c.sendResponse(writer, request, common.APIDeviceJob, job, http.StatusOK)
+	}
+}
+
+// Frame reports the tracker to bow frame transform in use.
+func (c *RestController) Frame(writer http.ResponseWriter, request *http.Request) {
+	c.sendResponse(writer, request, common.APIDeviceFrame, c.Client.Frame(), http.StatusOK)
+}
+
+// CalibrateFrame solves the frame transform from paired reference points.
+func (c *RestController) CalibrateFrame(writer http.ResponseWriter, request *http.Request) {
+	var calibrateRequest configmap.CalibrateFrameRequest
+	err := json.NewDecoder(request.Body).Decode(&calibrateRequest)
+	if err != nil {
+		klog.Error("Bad request, failed to decode JSON: ", err)
+		c.sendMapperError(writer, request, err.Error(), common.APIDeviceCalibrateFrame)
+		return
+	}
+
+	pairs := make([]driver.PointPair, 0, len(calibrateRequest.Points))
+	for _, point := range calibrateRequest.Points {
+		pairs = append(pairs, driver.PointPair{Tracker: point.Tracker, Platform: point.Platform})
+	}
+	calibration, err := c.Client.CalibrateFrame(pairs, calibrateRequest.LengthUnit, calibrateRequest.MaxResidual)
+	if err != nil {
+		if len(calibration.Residuals) != 0 {
+			c.sendMapperReport(writer, request, calibration, common.KindRangeNotSatisfiable,
+				common.APIDeviceCalibrateFrame, "frame calibration refused: %v", err)
+			return
+		}
+		c.sendMapperError(writer, request, err.Error(), common.APIDeviceCalibrateFrame)
+		return
+	}
+	c.sendResponse(writer, request, common.APIDeviceCalibrateFrame, calibration, http.StatusOK)
+}
+
+// Analysis reports the incisal and condylar path analysis of a segment.
+func (c *RestController) Analysis(writer http.ResponseWriter, request *http.Request) {
+	segment := mux.Vars(request)[common.Segment]
+	analysis, err := c.Client.AnalyzeSegment(segment)
+	if err != nil {
+		c.sendMapperError(writer, request, err.Error(), common.APIDeviceAnalysis)
+		return
+	}
+	c.sendResponse(writer, request, common.APIDeviceAnalysis, analysis, http.StatusOK)
+}
+
+// Cylinders reports the calibration of the cylinder encoders.
+func (c *RestController) Cylinders(writer http.ResponseWriter, request *http.Request) {
+	c.sendResponse(writer, request, common.APIDeviceCalibrateCylinders, c.Client.Calibration(), http.StatusOK)
+}
+
+// CalibrateCylinders applies and stores the measured cylinder offsets.
+func (c *RestController) CalibrateCylinders(writer http.ResponseWriter, request *http.Request) {
+	var calibrateRequest configmap.CalibrateCylindersRequest
+	err := json.NewDecoder(request.Body).Decode(&calibrateRequest)
+	if err != nil {
+		klog.Error("Bad request, failed to decode JSON: ", err)
+		c.sendMapperError(writer, request, err.Error(), common.APIDeviceCalibrateCylinders)
+		return
+	}
+	calibration, err := c.Client.CalibrateOffsets(calibrateRequest.Offsets)
+	switch {
+	case errors.Is(err, driver.ErrInvalidCalibration):
+		c.sendMapperReport(writer, request, calibration, common.KindRangeNotSatisfiable,
+			common.APIDeviceCalibrateCylinders, "cylinder calibration refused: %v", err)
+	case errors.Is(err, driver.ErrCalibrationBusy):
+		c.sendMapperReport(writer, request, calibration, common.KindNotAllowed,
+			common.APIDeviceCalibrateCylinders, "%v", err)
+	case err != nil:
+		c.sendMapperError(writer, request, err.Error(), common.APIDeviceCalibrateCylinders)
+	default:
+		c.sendResponse(writer, request, common.APIDeviceCalibrateCylinders, calibration, http.StatusOK)
+	}
 }
@@ -0,0 +1,226 @@
+package httpadapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/configmap"
+	"github.com/smilelinkd/digitalbow-mapper/driver"
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// discardPort is a serial port taking every frame and never answering.
+type discardPort struct{}
+
+func (discardPort) Write(b []byte) (int, error) { return len(b), nil }
+func (discardPort) Read(b []byte) (int, error)  { return 0, io.EOF }
+func (discardPort) Close() error                { return nil }
+
+// ports counts the clients of the tests. The driver keeps one client per
+// serial port, so every client gets a port of its own.
+var ports int
+
+func testPort(t *testing.T) string {
+	ports++
+	return fmt.Sprintf("%s-%d", t.Name(), ports)
+}
+
+// newTestController returns the routes of a client on a symmetric test
+// platform, Ready on a discardPort when connected.
+func newTestController(t *testing.T, connected bool) *RestController {
+	client, err := driver.NewClient(driver.BowRTUConfig{
+		SerialName: testPort(t),
+		Geometry: kinematics.Geometry{
+			BaseJoints:     kinematics.HexagonJoints(0.25, 15),
+			PlatformJoints: kinematics.HexagonJoints(0.15, 45),
+			LegLength:      0.4,
+			HomeStroke:     0.1569,
+		},
+		Frame:          driver.DefaultFrameConfig(),
+		StopRamp:       50 * time.Millisecond,
+		SeekTransition: 20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	controller := NewRestController(mux.NewRouter(), client)
+	controller.InitRestRoutes()
+	if connected {
+		client.ConnectWith(func() (io.ReadWriteCloser, error) { return discardPort{}, nil })
+		t.Cleanup(func() { _ = client.Close() })
+		require.Eventually(t, func() bool { return client.GetStatus() == common.StatusReady },
+			time.Second, time.Millisecond)
+	}
+	return controller
+}
+
+// serve sends a request with body encoded as JSON, none when nil.
+func serve(t *testing.T, c *RestController, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	}
+	recorder := httptest.NewRecorder()
+	c.Router.ServeHTTP(recorder, httptest.NewRequest(method, path, reader))
+	return recorder
+}
+
+// loadTrack loads a still segment of frames frames at 10 Hz.
+func loadTrack(t *testing.T, c *RestController, segment string, frames int) {
+	identity := [4][4]float64{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+	track := driver.TrackData{Size: frames, Frequency: 10, MatrixInit: identity}
+	track.MatrixList = make([][4][4]float64, frames)
+	content, err := json.Marshal(track)
+	require.NoError(t, err)
+	_, err = c.Client.LoadTrack(segment, content, false, "")
+	require.NoError(t, err)
+}
+
+func waitJob(t *testing.T, c *RestController, id string, state driver.JobState) driver.Job {
+	var job driver.Job
+	require.Eventually(t, func() bool {
+		recorder := serve(t, c, http.MethodGet, common.APIDeviceJobs+"/"+id, nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &job))
+		return job.State == state
+	}, 2*time.Second, 5*time.Millisecond, "job %s never %s", id, state)
+	return job
+}
+
+func TestControlNotRunning(t *testing.T) {
+	c := newTestController(t, true)
+	frame := 1
+
+	for _, test := range []struct {
+		path string
+		body interface{}
+	}{
+		{common.APIDeviceStop, configmap.StopRequest{Reason: "test"}},
+		{common.APIDeviceStop, nil},
+		{common.APIDevicePause, nil},
+		{common.APIDeviceResume, nil},
+		{common.APIDeviceSeek, configmap.SeekRequest{Frame: &frame}},
+	} {
+		recorder := serve(t, c, http.MethodPost, test.path, test.body)
+		assert.Equal(t, http.StatusConflict, recorder.Code, test.path)
+	}
+	assert.Equal(t, common.StatusReady, c.Client.GetStatus())
+
+	recorder := serve(t, c, http.MethodGet, common.APIDeviceJobs+"/missing", nil)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, recorder.Code)
+	recorder = serve(t, c, http.MethodDelete, common.APIDeviceJobs+"/missing", nil)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, recorder.Code)
+}
+
+func TestExecuteJob(t *testing.T) {
+	c := newTestController(t, true)
+	loadTrack(t, c, "chewing", 100)
+
+	recorder := serve(t, c, http.MethodPost, common.APIDeviceExecute,
+		configmap.ExecuteRequest{Random: true, Input: []float32{1, 2, 3}})
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	recorder = serve(t, c, http.MethodPost, common.APIDeviceExecute,
+		configmap.ExecuteRequest{Segment: "chewing", Start: 90, End: 10})
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Empty(t, c.Client.Jobs())
+
+	recorder = serve(t, c, http.MethodPost, common.APIDeviceExecute, configmap.ExecuteRequest{Segment: "chewing"})
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	var job driver.Job
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &job))
+	assert.NotEmpty(t, job.ID)
+	assert.Equal(t, "chewing", job.Segment)
+	assert.Equal(t, 100, job.Frames)
+	waitJob(t, c, job.ID, driver.JobRunning)
+
+	recorder = serve(t, c, http.MethodDelete, common.APIDeviceJobs+"/"+job.ID, nil)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	recorder = serve(t, c, http.MethodPost, common.APIDevicePause, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var status driver.ExecutionStatus
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	assert.Equal(t, common.StatusPaused, status.Status)
+	assert.Equal(t, "chewing", status.Segment)
+
+	frame, outside := 50, 1000
+	recorder = serve(t, c, http.MethodPost, common.APIDeviceSeek, configmap.SeekRequest{Frame: &outside})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, recorder.Code)
+	recorder = serve(t, c, http.MethodPost, common.APIDeviceSeek, configmap.SeekRequest{Frame: &frame})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	require.Eventually(t, func() bool { return c.Client.ExecutionStatus().Frame == frame },
+		time.Second, time.Millisecond)
+
+	recorder = serve(t, c, http.MethodPost, common.APIDeviceResume, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	assert.Equal(t, common.StatusExecucting, status.Status)
+
+	recorder = serve(t, c, http.MethodPost, common.APIDeviceStop, configmap.StopRequest{Reason: "test", By: "operator"})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var record driver.StopRecord
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &record))
+	assert.Equal(t, "operator", record.By)
+	assert.Equal(t, "chewing", record.Segment)
+	stopped := waitJob(t, c, job.ID, driver.JobStopped)
+	require.NotNil(t, stopped.Stop)
+	assert.Equal(t, "test", stopped.Stop.Reason)
+
+	recorder = serve(t, c, http.MethodPost, common.APIDeviceStop, nil)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	recorder = serve(t, c, http.MethodGet, common.APIDeviceJobs, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var jobs []driver.Job
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &jobs))
+	require.Len(t, jobs, 1)
+	assert.Equal(t, job.ID, jobs[0].ID)
+}
+
+func TestExecuteNotReady(t *testing.T) {
+	c := newTestController(t, false)
+	loadTrack(t, c, "chewing", 10)
+
+	recorder := serve(t, c, http.MethodPost, common.APIDeviceExecute, configmap.ExecuteRequest{Segment: "chewing"})
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Empty(t, c.Client.Jobs())
+}
+
+func TestHomeHandlers(t *testing.T) {
+	c := newTestController(t, true)
+
+	// Homing needs the protocol extensions, off here.
+	recorder := serve(t, c, http.MethodPost, common.APIDeviceHome, nil)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	recorder = serve(t, c, http.MethodGet, common.APIDeviceHome, nil)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, recorder.Code)
+	assert.Equal(t, common.StatusReady, c.Client.GetStatus())
+}
+
+func TestLockHandlers(t *testing.T) {
+	c := newTestController(t, true)
+
+	recorder := serve(t, c, http.MethodPost, common.APIDeviceLock, configmap.LockRequest{Reason: "maintenance"})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, common.StatusLocked, c.Client.GetStatus())
+	recorder = serve(t, c, http.MethodPost, common.APIDeviceExecute, configmap.ExecuteRequest{Random: true})
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+	recorder = serve(t, c, http.MethodPost, common.APIDeviceUnlock, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var status driver.ExecutionStatus
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	assert.Equal(t, common.StatusReady, status.Status)
+	recorder = serve(t, c, http.MethodPost, common.APIDeviceUnlock, nil)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+}
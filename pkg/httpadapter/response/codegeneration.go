@@ -20,12 +20,16 @@ func CodeMapping(kind common.ErrKind) int {
 		return http.StatusBadGateway
 	case common.KindServiceUnavailable:
 		return http.StatusServiceUnavailable
+	case common.KindNotAllowed:
+		return http.StatusConflict
 	case common.KindServiceLocked:
 		return http.StatusLocked
 	case common.KindNotImplemented:
 		return http.StatusNotImplemented
 	case common.KindRangeNotSatisfiable:
 		return http.StatusRequestedRangeNotSatisfiable
+	case common.KindContractInvalid:
+		return http.StatusBadRequest
 	case common.KindOverflowError, common.KindNaNError:
 		return http.StatusInternalServerError
 	default:
@@ -36,6 +36,24 @@ func (c *RestController) InitRestRoutes() {
 	c.addReservedRoute(common.APIPingRoute, c.Ping).Methods(http.MethodGet)
 	c.addReservedRoute(common.APIDeviceDownload, c.Download).Methods(http.MethodPost)
 	c.addReservedRoute(common.APIDeviceExecute, c.Execute).Methods(http.MethodPost)
+	c.addReservedRoute(common.APIDeviceStop, c.Stop).Methods(http.MethodPost)
+	c.addReservedRoute(common.APIDevicePause, c.Pause).Methods(http.MethodPost)
+	c.addReservedRoute(common.APIDeviceResume, c.Resume).Methods(http.MethodPost)
+	c.addReservedRoute(common.APIDeviceSeek, c.Seek).Methods(http.MethodPost)
+	c.addReservedRoute(common.APIDeviceHome, c.Home).Methods(http.MethodPost)
+	c.addReservedRoute(common.APIDeviceHome, c.LastHoming).Methods(http.MethodGet)
+	c.addReservedRoute(common.APIDeviceLock, c.Lock).Methods(http.MethodPost)
+	c.addReservedRoute(common.APIDeviceUnlock, c.Unlock).Methods(http.MethodPost)
+	c.addReservedRoute(common.APIDeviceStatus, c.Status).Methods(http.MethodGet)
+	c.addReservedRoute(common.APIDeviceStatusHistory, c.StatusHistory).Methods(http.MethodGet)
+	c.addReservedRoute(common.APIDeviceJobs, c.Jobs).Methods(http.MethodGet)
+	c.addReservedRoute(common.APIDeviceJob, c.Job).Methods(http.MethodGet)
+	c.addReservedRoute(common.APIDeviceJob, c.CancelJob).Methods(http.MethodDelete)
+	c.addReservedRoute(common.APIDeviceFrame, c.Frame).Methods(http.MethodGet)
+	c.addReservedRoute(common.APIDeviceCalibrateFrame, c.CalibrateFrame).Methods(http.MethodPost)
+	c.addReservedRoute(common.APIDeviceCalibrateCylinders, c.Cylinders).Methods(http.MethodGet)
+	c.addReservedRoute(common.APIDeviceCalibrateCylinders, c.CalibrateCylinders).Methods(http.MethodPost)
+	c.addReservedRoute(common.APIDeviceAnalysis, c.Analysis).Methods(http.MethodGet)
 }
 
 func (c *RestController) addReservedRoute(route string, handler func(http.ResponseWriter, *http.Request)) *mux.Route {
@@ -56,6 +74,23 @@ func (c *RestController) sendMapperError(
 	c.sendResponse(writer, request, API, err, response.CodeMapping(common.KindServerError))
 }
 
+// sendMapperReport rejects the request with a report explaining why.
+func (c *RestController) sendMapperReport(
+	writer http.ResponseWriter,
+	request *http.Request,
+	report interface{},
+	kind common.ErrKind,
+	API string,
+	format string,
+	args ...interface{}) {
+	correlationID := request.Header.Get(common.CorrelationHeader)
+	if correlationID == "" {
+		correlationID = "nil"
+	}
+	klog.Errorf("correlationID :%s error : %s", correlationID, fmt.Sprintf(format, args...))
+	c.sendResponse(writer, request, API, report, response.CodeMapping(kind))
+}
+
 // sendResponse puts together the response packet for the V2 API
 func (c *RestController) sendResponse(
 	writer http.ResponseWriter,
@@ -0,0 +1,97 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// PTY is a pseudo-terminal pair. The simulator serves Master; the mapper
+// opens SlaveName as its serial port.
+type PTY struct {
+	Master    *os.File
+	SlaveName string
+	// slave is kept open so that reading Master does not fail with EIO while
+	// the mapper has not opened the port yet.
+	slave *os.File
+}
+
+// OpenPTY opens a new pseudo-terminal in raw mode.
+func OpenPTY() (*PTY, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	var number uint32
+	if err = ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("get pty number: %v", err)
+	}
+	var unlock int32
+	if err = ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("unlock pty: %v", err)
+	}
+
+	name := fmt.Sprintf("/dev/pts/%d", number)
+	slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	if err = makeRaw(slave.Fd()); err != nil {
+		slave.Close()
+		master.Close()
+		return nil, fmt.Errorf("set pty raw mode: %v", err)
+	}
+	return &PTY{Master: master, SlaveName: name, slave: slave}, nil
+}
+
+// Close closes both ends.
+func (p *PTY) Close() error {
+	p.slave.Close()
+	return p.Master.Close()
+}
+
+// makeRaw disables echo and line editing, which would otherwise send the
+// simulator its own frames back.
+func makeRaw(fd uintptr) error {
+	var termios syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, uintptr(unsafe.Pointer(&termios))); err != nil {
+		return err
+	}
+	termios.Iflag = 0
+	termios.Oflag = 0
+	termios.Lflag = 0
+	termios.Cflag = syscall.CS8 | syscall.CREAD | syscall.CLOCAL
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+	return ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&termios)))
+}
+
+func ioctl(fd, request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
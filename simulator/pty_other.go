@@ -0,0 +1,42 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+// PTY is a pseudo-terminal pair, only available on Linux.
+type PTY struct {
+	Master    *os.File
+	SlaveName string
+}
+
+// OpenPTY is not supported outside Linux.
+func OpenPTY() (*PTY, error) {
+	return nil, errors.New("pseudo-terminals are not supported on " + runtime.GOOS)
+}
+
+// Close does nothing.
+func (p *PTY) Close() error {
+	return nil
+}
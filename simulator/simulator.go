@@ -0,0 +1,331 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulator is a virtual bow controller speaking the serial frame
+// protocol, for testing the mapper without the platform.
+package simulator
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+)
+
+// Fault codes raised by the simulator.
+const (
+	// FaultOverTravel is raised when a commanded position is out of stroke.
+	FaultOverTravel byte = 1
+	// FaultInjected is the default code of Config.FaultAfter.
+	FaultInjected byte = 0x10
+)
+
+// Ack codes of refused commands.
+const (
+	AckRefusedFault byte = 1
+)
+
+// Config is the behaviour of the simulated controller.
+type Config struct {
+	// Address is the bus address of the controller.
+	Address byte
+	// Version is answered to a handshake.
+	Version protocol.Version
+	// SlewRate is the cylinder speed in meters per second.
+	SlewRate float64
+	// ReportRate is how often in Hz the positions are reported.
+	ReportRate int
+	// MinLength and MaxLength are the cylinder stroke in meters; commands out
+	// of it raise FaultOverTravel.
+	MinLength float64
+	MaxLength float64
+	// FaultAfter raises FaultCode after this many set-positions frames when
+	// not zero.
+	FaultAfter int
+	FaultCode  byte
+}
+
+// DefaultConfig returns a controller moving 50 mm/s over ±20 mm of stroke.
+func DefaultConfig() Config {
+	return Config{
+		Address:    1,
+		Version:    protocol.Version{Major: 1, Minor: 0},
+		SlewRate:   0.05,
+		ReportRate: 50,
+		MinLength:  protocol.StrokeZero - 0.02,
+		MaxLength:  protocol.StrokeZero + 0.02,
+		FaultCode:  FaultInjected,
+	}
+}
+
+// State is a snapshot of the simulated controller.
+type State struct {
+	Positions [protocol.Cylinders]float64
+	Targets   [protocol.Cylinders]float64
+	State     byte
+	Fault     byte
+	// Frames counts the set-positions frames received.
+	Frames int
+}
+
+// Simulator is a simulated bow controller.
+type Simulator struct {
+	config Config
+
+	mu    sync.Mutex
+	state State
+	// writeMu serializes the frames written by the reader and the reporter.
+	writeMu sync.Mutex
+	w       io.Writer
+}
+
+// New returns a simulator with every cylinder at home.
+func New(config Config) *Simulator {
+	if config.ReportRate <= 0 {
+		config.ReportRate = DefaultConfig().ReportRate
+	}
+	if config.FaultCode == 0 {
+		config.FaultCode = FaultInjected
+	}
+	s := &Simulator{config: config}
+	for i := range s.state.Positions {
+		s.state.Positions[i] = protocol.StrokeZero
+		s.state.Targets[i] = protocol.StrokeZero
+	}
+	return s
+}
+
+// State returns a snapshot of the simulated controller.
+func (s *Simulator) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// InjectFault raises a controller fault.
+func (s *Simulator) InjectFault(code byte) {
+	s.mu.Lock()
+	s.raise(code)
+	status := s.status()
+	s.mu.Unlock()
+	s.send(protocol.CommandStatus, status.Payload())
+}
+
+// ClearFault clears the controller fault.
+func (s *Simulator) ClearFault() {
+	s.mu.Lock()
+	s.state.Fault, s.state.State = 0, protocol.StateIdle
+	s.state.Targets = s.state.Positions
+	status := s.status()
+	s.mu.Unlock()
+	s.send(protocol.CommandStatus, status.Payload())
+}
+
+// raise faults the controller and freezes the cylinders. s.mu is held.
+func (s *Simulator) raise(code byte) {
+	klog.Warningf("Simulated controller fault %d", code)
+	s.state.Fault, s.state.State = code, protocol.StateFault
+	s.state.Targets = s.state.Positions
+}
+
+func (s *Simulator) status() protocol.Status {
+	return protocol.Status{State: s.state.State, Fault: s.state.Fault}
+}
+
+// Serve answers the frames read from rw and reports the cylinder positions
+// until reading fails or stop is closed.
+func (s *Simulator) Serve(rw io.ReadWriter, stop <-chan struct{}) error {
+	s.writeMu.Lock()
+	s.w = rw
+	s.writeMu.Unlock()
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.report(stop, done)
+
+	decoder := protocol.NewDecoder(rw)
+	for {
+		frame, err := decoder.ReadFrame()
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		if errors.Is(err, protocol.ErrChecksum) {
+			klog.Warningf("Simulator dropped frame: %v", err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.handle(frame)
+	}
+}
+
+// handle answers one frame.
+func (s *Simulator) handle(frame protocol.Frame) {
+	if frame.Address != protocol.BroadcastAddress && frame.Address != s.config.Address {
+		return
+	}
+	parse := protocol.ParseMessage
+	if frame.Command.Extension() {
+		parse = protocol.ParseExtension
+	}
+	message, err := parse(frame)
+	if err != nil {
+		klog.Warningf("Simulator refused %v frame: %v", frame.Command, err)
+		return
+	}
+
+	code := byte(0)
+	s.mu.Lock()
+	switch m := message.(type) {
+	case protocol.Positions:
+		if frame.Command != protocol.CommandSetPositions {
+			s.mu.Unlock()
+			return
+		}
+		s.state.Frames++
+		if s.state.Fault != 0 {
+			code = AckRefusedFault
+			break
+		}
+		if s.config.FaultAfter != 0 && s.state.Frames >= s.config.FaultAfter {
+			s.raise(s.config.FaultCode)
+			code = AckRefusedFault
+			break
+		}
+		for i, count := range m {
+			length := protocol.Length(count)
+			if length < s.config.MinLength-1e-9 || length > s.config.MaxLength+1e-9 {
+				s.raise(FaultOverTravel)
+				code = AckRefusedFault
+				break
+			}
+			s.state.Targets[i] = length
+		}
+		if code == 0 {
+			s.state.State = protocol.StateMoving
+		}
+	case protocol.Version:
+		// A handshake reply is not a request.
+		s.mu.Unlock()
+		return
+	default:
+		switch frame.Command {
+		case protocol.CommandHandshake:
+			s.mu.Unlock()
+			s.send(protocol.CommandHandshake, s.config.Version.Payload())
+			return
+		case protocol.CommandHome:
+			if s.state.Fault != 0 {
+				code = AckRefusedFault
+				break
+			}
+			for i := range s.state.Targets {
+				s.state.Targets[i] = protocol.StrokeZero
+			}
+			s.state.State = protocol.StateHoming
+		case protocol.CommandStop:
+			s.state.Targets = s.state.Positions
+			if s.state.Fault == 0 {
+				s.state.State = protocol.StateStopped
+			}
+		default:
+			s.mu.Unlock()
+			return
+		}
+	}
+	status := s.status()
+	s.mu.Unlock()
+
+	s.send(protocol.CommandAck, protocol.Ack{Command: frame.Command, Code: code}.Payload())
+	if code != 0 {
+		s.send(protocol.CommandStatus, status.Payload())
+	}
+}
+
+// report moves the cylinders toward their targets at the slew rate and
+// reports their positions, and the status whenever it changes.
+func (s *Simulator) report(stop, done <-chan struct{}) {
+	period := time.Second / time.Duration(s.config.ReportRate)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	last := time.Now()
+	var reported protocol.Status
+	for {
+		select {
+		case <-stop:
+			return
+		case <-done:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			s.step(now.Sub(last).Seconds())
+			last = now
+			var positions protocol.Positions
+			for i, length := range s.state.Positions {
+				positions[i], _ = protocol.Count(length)
+			}
+			status := s.status()
+			s.mu.Unlock()
+
+			s.send(protocol.CommandPositions, positions.Payload())
+			if status != reported {
+				s.send(protocol.CommandStatus, status.Payload())
+				reported = status
+			}
+		}
+	}
+}
+
+// step moves the cylinders for dt seconds. s.mu is held.
+func (s *Simulator) step(dt float64) {
+	moving := false
+	for i := range s.state.Positions {
+		delta := s.state.Targets[i] - s.state.Positions[i]
+		if limit := s.config.SlewRate * dt; math.Abs(delta) > limit {
+			delta = math.Copysign(limit, delta)
+			moving = true
+		}
+		s.state.Positions[i] += delta
+	}
+	if !moving && (s.state.State == protocol.StateMoving || s.state.State == protocol.StateHoming) {
+		s.state.State = protocol.StateIdle
+	}
+}
+
+// send writes a frame from the controller.
+func (s *Simulator) send(command protocol.Command, payload []byte) {
+	data, err := protocol.Frame{Address: s.config.Address, Command: command, Payload: payload}.Encode()
+	if err != nil {
+		klog.Errorf("Simulator encode %v: %v", command, err)
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.w == nil {
+		return
+	}
+	if _, err = s.w.Write(data); err != nil {
+		klog.V(2).Infof("Simulator write %v: %v", command, err)
+	}
+}
@@ -0,0 +1,78 @@
+//go:build linux
+// +build linux
+
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver"
+	"github.com/smilelinkd/digitalbow-mapper/driver/kinematics"
+	"github.com/smilelinkd/digitalbow-mapper/pkg/common"
+)
+
+// TestMapperOnPTY drives the simulator through the mapper's serial session.
+func TestMapperOnPTY(t *testing.T) {
+	pty, err := OpenPTY()
+	if err != nil {
+		t.Skipf("no pseudo-terminal: %v", err)
+	}
+	defer pty.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	sim := New(DefaultConfig())
+	go sim.Serve(pty.Master, stop)
+
+	client, err := driver.NewClient(driver.BowRTUConfig{
+		SerialName: pty.SlaveName,
+		BaudRate:   115200,
+		Parity:     "even",
+		Timeout:    100 * time.Millisecond,
+		Solver:     driver.SolverGo,
+		Geometry: kinematics.Geometry{
+			BaseJoints:     kinematics.HexagonJoints(0.25, 15),
+			PlatformJoints: kinematics.HexagonJoints(0.15, 45),
+			LegLength:      0.4,
+			HomeStroke:     0.1569,
+		},
+		Frame: driver.DefaultFrameConfig(),
+		// The simulator reports its feedback with the protocol extensions.
+		ProtocolExtensions: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+	require.Eventually(t, client.Connected, time.Second, time.Millisecond)
+	assert.Equal(t, common.StatusReady, client.GetStatus())
+
+	clylen := make([]float32, kinematics.Legs)
+	client.Client.Execute([]float32{0, 0, 0, 0, 0, 0.005}, clylen)
+	frame, err := client.AssembleSerialData(clylen)
+	require.NoError(t, err)
+	require.NoError(t, client.Write(frame))
+	require.Eventually(t, func() bool {
+		positions := client.Feedback().Positions
+		return len(positions) == kinematics.Legs && abs(positions[0]-clylen[0]) < 5e-5
+	}, 2*time.Second, 10*time.Millisecond)
+
+	frame, err = client.ResetToZero()
+	require.NoError(t, err)
+	require.NoError(t, client.Write(frame))
+	require.Eventually(t, func() bool {
+		return abs(float32(sim.State().Positions[0])-clylen[0]) > 1e-3
+	}, 2*time.Second, 10*time.Millisecond)
+
+	sim.InjectFault(7)
+	require.Eventually(t, func() bool { return client.GetStatus() == common.StatusFault }, time.Second, time.Millisecond)
+}
+
+func abs(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,115 @@
+package simulator
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smilelinkd/digitalbow-mapper/driver/protocol"
+)
+
+// startSimulator serves a simulator on a pipe and returns the mapper end and
+// the frames the simulator sends.
+func startSimulator(t *testing.T, config Config) (*Simulator, net.Conn, <-chan protocol.Frame) {
+	mapper, controller := net.Pipe()
+	stop := make(chan struct{})
+	sim := New(config)
+	go sim.Serve(controller, stop)
+	t.Cleanup(func() {
+		close(stop)
+		mapper.Close()
+		controller.Close()
+	})
+
+	frames := make(chan protocol.Frame, 1024)
+	go func() {
+		decoder := protocol.NewDecoder(mapper)
+		for {
+			frame, err := decoder.ReadFrame()
+			if err != nil {
+				return
+			}
+			select {
+			case frames <- frame:
+			default:
+			}
+		}
+	}()
+	return sim, mapper, frames
+}
+
+func send(t *testing.T, conn net.Conn, frame protocol.Frame) {
+	data, err := frame.Encode()
+	require.NoError(t, err)
+	_, err = conn.Write(data)
+	require.NoError(t, err)
+}
+
+// expect returns the next frame of command from frames.
+func expect(t *testing.T, frames <-chan protocol.Frame, command protocol.Command) protocol.Message {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case frame := <-frames:
+			if frame.Command == command {
+				// The simulator only answers with protocol extensions.
+				message, err := protocol.ParseExtension(frame)
+				require.NoError(t, err)
+				return message
+			}
+		case <-timeout:
+			t.Fatalf("no %v frame", command)
+		}
+	}
+}
+
+func TestSimulatorSlew(t *testing.T) {
+	config := DefaultConfig()
+	config.SlewRate = 0.1
+	config.ReportRate = 100
+	sim, conn, frames := startSimulator(t, config)
+
+	send(t, conn, protocol.Frame{Address: protocol.BroadcastAddress, Command: protocol.CommandHandshake})
+	assert.Equal(t, config.Version, expect(t, frames, protocol.CommandHandshake))
+
+	start := time.Now()
+	positions := protocol.Positions{400, -400, 200, 0, 0, 0}
+	send(t, conn, protocol.SetPositionsFrame(protocol.BroadcastAddress, positions))
+	assert.Equal(t, protocol.Ack{Command: protocol.CommandSetPositions}, expect(t, frames, protocol.CommandAck))
+	require.Eventually(t, func() bool { return sim.State().State == protocol.StateIdle }, time.Second, time.Millisecond)
+	// 10 mm at 100 mm/s.
+	assert.GreaterOrEqual(t, time.Since(start).Seconds(), 0.09)
+	for i, length := range positions.Lengths() {
+		assert.InDelta(t, length, sim.State().Positions[i], 1e-6)
+	}
+
+	send(t, conn, protocol.Frame{Address: protocol.BroadcastAddress, Command: protocol.CommandHome})
+	require.Eventually(t, func() bool { return sim.State().State == protocol.StateIdle }, time.Second, time.Millisecond)
+	assert.InDelta(t, protocol.StrokeZero, sim.State().Positions[0], 1e-9)
+}
+
+func TestSimulatorFaults(t *testing.T) {
+	config := DefaultConfig()
+	config.FaultAfter = 2
+	sim, conn, frames := startSimulator(t, config)
+
+	send(t, conn, protocol.SetPositionsFrame(protocol.BroadcastAddress, protocol.Positions{}))
+	assert.Equal(t, protocol.Ack{Command: protocol.CommandSetPositions}, expect(t, frames, protocol.CommandAck))
+	send(t, conn, protocol.SetPositionsFrame(protocol.BroadcastAddress, protocol.Positions{}))
+	assert.Equal(t, protocol.Ack{Command: protocol.CommandSetPositions, Code: AckRefusedFault}, expect(t, frames, protocol.CommandAck))
+	assert.Equal(t, protocol.Status{State: protocol.StateFault, Fault: FaultInjected}, expect(t, frames, protocol.CommandStatus))
+
+	sim.ClearFault()
+	assert.Equal(t, protocol.Status{State: protocol.StateIdle}, expect(t, frames, protocol.CommandStatus))
+
+	// 30 mm is beyond the ±20 mm stroke.
+	config.FaultAfter = 0
+	sim, conn, frames = startSimulator(t, config)
+	send(t, conn, protocol.SetPositionsFrame(protocol.BroadcastAddress, protocol.Positions{0, 1200, 0, 0, 0, 0}))
+	assert.Equal(t, protocol.Ack{Command: protocol.CommandSetPositions, Code: AckRefusedFault}, expect(t, frames, protocol.CommandAck))
+	assert.Equal(t, FaultOverTravel, sim.State().Fault)
+	assert.Equal(t, protocol.StrokeZero, sim.State().Targets[1])
+}